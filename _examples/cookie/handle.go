@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+// request router
+func requestRouter(ctx *fasthttp.RequestCtx) {
+	switch string(ctx.Path()) {
+	case "/":
+		indexHandler(ctx)
+	case "/set":
+		setHandler(ctx)
+	case "/get":
+		getHandler(ctx)
+	case "/delete":
+		deleteHandle(ctx)
+	case "/getAll":
+		getAllHandle(ctx)
+	case "/flush":
+		flushHandle(ctx)
+	case "/destroy":
+		destroyHandle(ctx)
+	case "/sessionid":
+		sessionIdHandle(ctx)
+	case "/regenerate":
+		regenerateHandle(ctx)
+	default:
+		ctx.Error("Unsupported path", fasthttp.StatusNotFound)
+	}
+}
+
+// index handler
+func indexHandler(ctx *fasthttp.RequestCtx) {
+
+	html := "<h2>Welcome to use fasthttpsession " + fasthttpsession.Version() + ", you should request to the: </h2>"
+
+	html += `> <a href="/">/</a><br>`
+	html += `> <a href="/set">set</a><br>`
+	html += `> <a href="/get">get</a><br>`
+	html += `> <a href="/delete">delete</a><br>`
+	html += `> <a href="/getAll">getAll</a><br>`
+	html += `> <a href="/flush">flush</a><br>`
+	html += `> <a href="/destroy">destroy</a><br>`
+	html += `> <a href="/sessionid">sessionid</a><br>`
+	html += `> <a href="/regenerate">regenerate</a><br>`
+
+	ctx.SetContentType("text/html;charset=utf-8")
+	ctx.SetBodyString(html)
+}
+
+// set handler
+func setHandler(ctx *fasthttp.RequestCtx) {
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	sessionStore.Set("name", "fasthttpsession")
+
+	name, _ := sessionStore.GetString("name")
+	ctx.SetBodyString(fmt.Sprintf("fasthttpsession setted key name= %s ok", name))
+}
+
+// get handler
+func getHandler(ctx *fasthttp.RequestCtx) {
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	s, err := sessionStore.GetString("name")
+	if err != nil {
+		ctx.SetBodyString("fasthttpsession get name error: " + err.Error())
+		return
+	}
+
+	ctx.SetBodyString(fmt.Sprintf("fasthttpsession get name= %s ok", s))
+}
+
+// delete handler
+func deleteHandle(ctx *fasthttp.RequestCtx) {
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	sessionStore.Delete("name")
+
+	s := sessionStore.Get("name")
+	if s == nil {
+		ctx.SetBodyString("fasthttpsession delete key name ok")
+		return
+	}
+	ctx.SetBodyString("fasthttpsession delete key name error")
+}
+
+// get all handler
+func getAllHandle(ctx *fasthttp.RequestCtx) {
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	sessionStore.Set("foo1", "baa1")
+	sessionStore.Set("foo2", "baa2")
+	sessionStore.Set("foo3", "baa3")
+	sessionStore.Set("foo4", "baa5")
+
+	data := sessionStore.GetAll()
+
+	fmt.Println(data)
+	ctx.SetBodyString("fasthttpsession get all data")
+}
+
+// flush handle
+func flushHandle(ctx *fasthttp.RequestCtx) {
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	sessionStore.Flush()
+
+	ctx.SetBodyString("fasthttpsession flush data")
+}
+
+// destroy handle
+func destroyHandle(ctx *fasthttp.RequestCtx) {
+	// destroy session
+	session.Destroy(ctx)
+
+	ctx.SetBodyString("fasthttpsession destroy")
+}
+
+// get sessionId handle
+func sessionIdHandle(ctx *fasthttp.RequestCtx) {
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	sessionId := sessionStore.GetSessionId()
+	ctx.SetBodyString("fasthttpsession sessionId: " + sessionId)
+}
+
+// regenerate handler
+func regenerateHandle(ctx *fasthttp.RequestCtx) {
+	// regenerate session, the middleware saves it after the handler
+	sessionStore, err := session.Regenerate(ctx)
+	if err != nil {
+		ctx.SetBodyString(err.Error())
+		return
+	}
+
+	sessionStore.Set("name", "foo")
+	sessionStore.Get("name")
+
+	sessionId := sessionStore.GetSessionId()
+
+	ctx.SetBodyString("fasthttpsession regenerate sessionId: " + sessionId)
+}
@@ -0,0 +1,50 @@
+package main
+
+// fasthttpsession cookie provider example
+
+import (
+	"log"
+	"os"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/brunohass/fasthttpsession/cookie"
+	"github.com/valyala/fasthttp"
+)
+
+// default config
+var session = fasthttpsession.NewSession(fasthttpsession.NewDefaultConfig())
+
+// custom config
+//var session = fasthttpsession.NewSession(&fasthttpsession.Config{
+//	CookieName: "ssid",
+//	Domain: "",
+//	Expires: time.Hour * 2,
+//	GCLifetime: 3,
+//	SessionLifetime: 60,
+//	Secure: true,
+//	SessionIdInURLQuery: false,
+//	SessionNameInUrlQuery: "",
+//	SessionIdInHttpHeader: false,
+//	SessionNameInHttpHeader: "",
+//	SessionIdGeneratorFunc: func() string {return ""},
+//	EncodeFunc: func(cookieValue string) (string, error) {return "", nil},
+//	DecodeFunc: func(cookieValue string) (string, error) {return "", nil},
+//})
+
+func main() {
+
+	// You must set up provider before use
+	// the session data is encrypted in the client cookie, use your own secret 32 bytes key
+	err := session.SetProvider(cookie.NewProvider(), cookie.NewConfigWith([]byte("fasthttpsession-example-key-0001")))
+	if err != nil {
+		log.Println(err.Error())
+		os.Exit(1)
+	}
+	addr := ":8086"
+	log.Println("fasthttpsession cookie example server listen: " + addr)
+	// Fasthttp start listen serve, the session middleware starts and saves the sessions
+	err = fasthttp.ListenAndServe(addr, session.Middleware(requestRouter))
+	if err != nil {
+		log.Println("listen server error :" + err.Error())
+	}
+}
@@ -34,7 +34,7 @@ var session = fasthttpsession.NewSession(fasthttpsession.NewDefaultConfig())
 func main() {
 
 	// You must set up provider before use
-	err := session.SetProvider("file", &file.Config{
+	err := session.SetProvider(file.NewProvider(), &file.Config{
 		SavePath: ".session", // session file save path
 	})
 
@@ -34,7 +34,7 @@ var session = fasthttpsession.NewSession(fasthttpsession.NewDefaultConfig())
 func main() {
 
 	// You must set up provider before use
-	err := session.SetProvider("memcache", &memcache.Config{
+	err := session.SetProvider(memcache.NewProvider(), &memcache.Config{
 		ServerList: []string{
 			"127.0.0.1:21122",
 			"127.0.0.1:21123",
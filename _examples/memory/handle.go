@@ -54,50 +54,45 @@ func indexHandler(ctx *fasthttp.RequestCtx) {
 
 // set handler
 func setHandler(ctx *fasthttp.RequestCtx) {
-	// start session
-	sessionStore, err := session.Start(ctx)
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
 	sessionStore.Set("name", "fasthttpsession")
 
-	ctx.SetBodyString(fmt.Sprintf("fasthttpsession setted key name= %s ok", sessionStore.Get("name").(string)))
+	name, _ := sessionStore.GetString("name")
+	ctx.SetBodyString(fmt.Sprintf("fasthttpsession setted key name= %s ok", name))
 }
 
 // get handler
 func getHandler(ctx *fasthttp.RequestCtx) {
-	// start session
-	sessionStore, err := session.Start(ctx)
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
-	s := sessionStore.Get("name")
-	if s == nil {
-		ctx.SetBodyString("fasthttpsession get name is nil")
+	s, err := sessionStore.GetString("name")
+	if err != nil {
+		ctx.SetBodyString("fasthttpsession get name error: " + err.Error())
 		return
 	}
 
-	ctx.SetBodyString(fmt.Sprintf("fasthttpsession get name= %s ok", s.(string)))
+	ctx.SetBodyString(fmt.Sprintf("fasthttpsession get name= %s ok", s))
 }
 
 // delete handler
 func deleteHandle(ctx *fasthttp.RequestCtx) {
-	// start session
-	sessionStore, err := session.Start(ctx)
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
 	sessionStore.Delete("name")
 
@@ -111,14 +106,12 @@ func deleteHandle(ctx *fasthttp.RequestCtx) {
 
 // get all handler
 func getAllHandle(ctx *fasthttp.RequestCtx) {
-	// start session
-	sessionStore, err := session.Start(ctx)
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
 	sessionStore.Set("foo1", "baa1")
 	sessionStore.Set("foo2", "baa2")
@@ -133,14 +126,12 @@ func getAllHandle(ctx *fasthttp.RequestCtx) {
 
 // flush handle
 func flushHandle(ctx *fasthttp.RequestCtx) {
-	// start session
-	sessionStore, err := session.Start(ctx)
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
 	sessionStore.Flush()
 
@@ -157,14 +148,12 @@ func destroyHandle(ctx *fasthttp.RequestCtx) {
 
 // get sessionId handle
 func sessionIdHandle(ctx *fasthttp.RequestCtx) {
-	// start session
-	sessionStore, err := session.Start(ctx)
+	// load session, the middleware saves it after the handler
+	sessionStore, err := session.LoadStore(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
 	sessionId := sessionStore.GetSessionId()
 	ctx.SetBodyString("fasthttpsession sessionId: " + sessionId)
@@ -172,14 +161,12 @@ func sessionIdHandle(ctx *fasthttp.RequestCtx) {
 
 // regenerate handler
 func regenerateHandle(ctx *fasthttp.RequestCtx) {
-	// start session
+	// regenerate session, the middleware saves it after the handler
 	sessionStore, err := session.Regenerate(ctx)
 	if err != nil {
 		ctx.SetBodyString(err.Error())
 		return
 	}
-	// must defer sessionStore.save(ctx)
-	defer sessionStore.Save(ctx)
 
 	sessionStore.Set("name", "foo")
 	sessionStore.Get("name")
@@ -34,15 +34,15 @@ var session = fasthttpsession.NewSession(fasthttpsession.NewDefaultConfig())
 func main() {
 
 	// You must set up provider before use
-	err := session.SetProvider("memory", &memory.Config{})
+	err := session.SetProvider(memory.NewProvider(), &memory.Config{})
 	if err != nil {
 		log.Println(err.Error())
 		os.Exit(1)
 	}
 	addr := ":8086"
 	log.Println("fasthttpsession memory example server listen: " + addr)
-	// Fasthttp start listen serve
-	err = fasthttp.ListenAndServe(addr, requestRouter)
+	// Fasthttp start listen serve, the session middleware starts and saves the sessions
+	err = fasthttp.ListenAndServe(addr, session.Middleware(requestRouter))
 	if err != nil {
 		log.Println("listen server error :" + err.Error())
 	}
@@ -65,7 +65,8 @@ func setHandler(ctx *fasthttp.RequestCtx) {
 
 	sessionStore.Set("name", "fasthttpsession")
 
-	ctx.SetBodyString(fmt.Sprintf("fasthttpsession setted key name= %s ok", sessionStore.Get("name").(string)))
+	name, _ := sessionStore.GetString("name")
+	ctx.SetBodyString(fmt.Sprintf("fasthttpsession setted key name= %s ok", name))
 }
 
 // get handler
@@ -79,13 +80,13 @@ func getHandler(ctx *fasthttp.RequestCtx) {
 	// must defer sessionStore.save(ctx)
 	defer sessionStore.Save(ctx)
 
-	s := sessionStore.Get("name")
-	if s == nil {
-		ctx.SetBodyString("fasthttpsession get name is nil")
+	s, err := sessionStore.GetString("name")
+	if err != nil {
+		ctx.SetBodyString("fasthttpsession get name error: " + err.Error())
 		return
 	}
 
-	ctx.SetBodyString(fmt.Sprintf("fasthttpsession get name= %s ok", s.(string)))
+	ctx.SetBodyString(fmt.Sprintf("fasthttpsession get name= %s ok", s))
 }
 
 // delete handler
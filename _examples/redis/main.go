@@ -34,7 +34,7 @@ var session = fasthttpsession.NewSession(fasthttpsession.NewDefaultConfig())
 func main() {
 
 	// You must set up provider before use
-	err := session.SetProvider("redis", &redis.Config{
+	err := session.SetProvider(redis.NewProvider(), &redis.Config{
 		Host:        "127.0.0.1",
 		Port:        6379,
 		MaxIdle:     8,
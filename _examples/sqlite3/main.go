@@ -34,7 +34,7 @@ var session = fasthttpsession.NewSession(fasthttpsession.NewDefaultConfig())
 func main() {
 
 	// You must set up provider before use
-	err := session.SetProvider("sqlite3", sqlite3.NewConfigWith("test.db", "session"))
+	err := session.SetProvider(sqlite3.NewProvider(), sqlite3.NewConfigWith("test.db", "session"))
 
 	if err != nil {
 		log.Println(err.Error())
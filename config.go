@@ -1,6 +1,8 @@
 package fasthttpsession
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/segmentio/ksuid"
@@ -13,6 +15,13 @@ var (
 	defaultExpires = time.Hour * 5
 
 	defaultGCLifetime = int64(3)
+
+	defaultLockTimeout = time.Second * 10
+
+	defaultUpdateRetries = 3
+
+	defaultSessionIdMinLength = 8
+	defaultSessionIdMaxLength = 128
 )
 
 // new default config
@@ -49,6 +58,15 @@ type Config struct {
 	// cookie domain
 	Domain string
 
+	// cookie path, default "/"
+	Path string
+
+	// emit the cookie Max-Age attribute instead of Expires, for an Expires > 0
+	MaxAge bool
+
+	// cookie partitioned attribute (CHIPS), it implies secure
+	Partitioned bool
+
 	// cookie sameSite attribute
 	SameSite fasthttp.CookieSameSite
 
@@ -68,9 +86,35 @@ type Config struct {
 	// session life time(s)
 	SessionLifetime int64
 
+	// absolute session life time(s) since the session creation, 0 means no limit
+	SessionAbsoluteLifetime int64
+
+	// timeout of the provider calls made on behalf of a request, 0 means no timeout
+	ProviderTimeout time.Duration
+
+	// lock the session for the whole request in Start, like StartLocked,
+	// the provider must implement LockProvider
+	LockSession bool
+
+	// max time to wait for the session lock, default 10s
+	LockTimeout time.Duration
+
+	// max retries of Session.Update on a save conflict, default 3, -1 means no retry
+	UpdateRetries int
+
 	// set whether to pass this bar cookie only through HTTPS
+	// a cookie name with the __Secure- or __Host- prefix is always secure,
+	// a __Host- cookie also has path "/" and no domain
 	Secure bool
 
+	// set the cookie secure attribute on every connection, not only on TLS connections,
+	// e.g. behind a TLS-terminating proxy
+	AlwaysSecure bool
+
+	// proxies trusted to tell a TLS connection by the Forwarded or X-Forwarded-Proto
+	// header, for Secure, see NewTrustedProxies
+	TrustedProxies *TrustedProxies
+
 	// sessionId is in url query
 	SessionIdInURLQuery bool
 
@@ -83,14 +127,79 @@ type Config struct {
 	// sessionName in http header
 	SessionNameInHttpHeader string
 
+	// session id extractors, tried in order, the first value found is the session id.
+	// nil means the cookie, then the url query and the http header enabled above.
+	// e.g. []Extractor{NewHeaderExtractor("X-Session"), NewBearerExtractor()}
+	Extractors []Extractor
+
+	// session id injectors, Start and Regenerate write the session id with all of them.
+	// nil means the cookie, and the http header if SessionIdInHttpHeader.
+	Injectors []Injector
+
+	// FingerprintFunc returns the client fingerprint bound to a new session if not nil,
+	// Start checks it on every request, e.g. FingerprintIP(24, 64) or FingerprintUserAgent.
+	// the session keeps a hash of the fingerprint.
+	FingerprintFunc func(ctx *fasthttp.RequestCtx) string
+
+	// policy applied on a fingerprint mismatch, default FingerprintDestroy
+	FingerprintPolicy FingerprintPolicy
+
+	// called on a fingerprint mismatch before the policy is applied, e.g. to log a stolen cookie
+	OnFingerprintMismatch func(ctx *fasthttp.RequestCtx, sessionStore SessionStore)
+
+	// session lifecycle callbacks
+	Hooks Hooks
+
+	// session and provider metrics, nil means none, see NewPrometheusMetrics
+	Metrics Metrics
+
+	// provider label of the metrics, default the provider config name, e.g. "redis".
+	// the sessions sharing a label are summed, set it to tell apart two sessions of a provider.
+	MetricsLabel string
+
+	// tracer of the provider calls and their backend round-trips, nil means none
+	Tracer Tracer
+
+	// parent context of the provider calls, e.g. the context of the request span
+	// kept by a tracing middleware, nil means context.Background()
+	ParentContextFunc func(ctx *fasthttp.RequestCtx) context.Context
+
+	// logger of the failures which can not be returned, e.g. in gc,
+	// nil means NewStdLogger(nil), NewNopLogger() discards them
+	Logger Logger
+
 	// SessionIdGeneratorFunc should returns a random session id.
 	SessionIdGeneratorFunc func() string
 
+	// SessionIdValidatorFunc reports whether a session id sent by the client is well-formed,
+	// e.g. DefaultSessionIdValidator. nil checks nothing, except with StrictSessionId
+	// which uses DefaultSessionIdValidator.
+	// an invalid session id is discarded before the provider reads it.
+	SessionIdValidatorFunc func(sessionId string) bool
+
+	// discard the session ids sent by the client which are unknown to the provider,
+	// or rejected by the session id validator, a new session id is generated instead.
+	// the provider must implement ExistsProvider.
+	StrictSessionId bool
+
 	// Encode the cookie value if not nil.
 	EncodeFunc func(cookieValue string) (string, error)
 
 	// Decode the cookie value if not nil.
 	DecodeFunc func(cookieValue string) (string, error)
+
+	// HMAC-SHA256 keys signing the session id, id.signature, if not empty.
+	// the first key signs, all keys verify, keep the previous keys during a rotation.
+	// a session id with an invalid signature is rejected before the provider reads it.
+	// every key must be at least 32 bytes, else Session.SetProvider fails.
+	SigningKeys [][]byte
+
+	// signer of the SigningKeys, built by NewSession
+	signer *Signer
+
+	// ErrorHandlerFunc handles the session middleware errors if not nil,
+	// the default handler responds 500 Internal Server Error.
+	ErrorHandlerFunc func(ctx *fasthttp.RequestCtx, err error)
 }
 
 // sessionId generator
@@ -108,33 +217,112 @@ func (c *Config) defaultSessionIdGenerator() string {
 	return ksuid.New().String()
 }
 
-// encode cookie value
+// sessionId validator
+// without SessionIdValidatorFunc, the session ids are only checked by
+// DefaultSessionIdValidator with StrictSessionId
+func (c *Config) SessionIdValidator(sessionId string) bool {
+	sessionIdValidator := c.SessionIdValidatorFunc
+	if sessionIdValidator == nil {
+		if c.StrictSessionId {
+			return DefaultSessionIdValidator(sessionId)
+		}
+		return true
+	}
+
+	return sessionIdValidator(sessionId)
+}
+
+// DefaultSessionIdValidator accepts 8 to 128 characters of [A-Za-z0-9_-],
+// the session ids of the default generator
+func DefaultSessionIdValidator(sessionId string) bool {
+	if len(sessionId) < defaultSessionIdMinLength || len(sessionId) > defaultSessionIdMaxLength {
+		return false
+	}
+	for i := 0; i < len(sessionId); i++ {
+		ch := sessionId[i]
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_' || ch == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// get the signer of the SigningKeys, the one built by NewSession if any
+func (c *Config) getSigner() *Signer {
+	if c.signer != nil {
+		return c.signer
+	}
+	return NewSigner(c.SigningKeys...)
+}
+
+// session middleware error handler
+func (c *Config) ErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	errorHandler := c.ErrorHandlerFunc
+	if errorHandler != nil {
+		errorHandler(ctx, err)
+		return
+	}
+	ctx.Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError), fasthttp.StatusInternalServerError)
+}
+
+// encode cookie value, empty if the encoding failed, the error is logged
 func (c *Config) Encode(cookieValue string) string {
+	encodedValue, err := c.EncodeSessionId(cookieValue)
+	if err != nil {
+		c.logger().Error("session id encode error", "op", "encode", "sessionId", cookieValue, "error", err)
+		return ""
+	}
+	return encodedValue
+}
+
+// decode cookie value, empty if the decoding failed, see DecodeSessionId
+func (c *Config) Decode(cookieValue string) string {
+	sessionId, err := c.DecodeSessionId(cookieValue)
+	if err != nil {
+		return ""
+	}
+	return sessionId
+}
+
+// encode the session id for the cookie, header or query value
+// 1. sign sessionId if SigningKeys is set
+// 2. encode by EncodeFunc if not nil
+func (c *Config) EncodeSessionId(sessionId string) (string, error) {
+	if len(c.SigningKeys) > 0 {
+		sessionId = c.getSigner().Sign(sessionId)
+	}
 	encode := c.EncodeFunc
 	if encode != nil {
-		newVal, err := encode(cookieValue)
-		if err == nil {
-			cookieValue = newVal
-		} else {
-			cookieValue = ""
-		}
+		return encode(sessionId)
 	}
-	return cookieValue
+	return sessionId, nil
 }
 
-// decode cookie value
-func (c *Config) Decode(cookieValue string) string {
+// decode the session id of a cookie, header or query value, the error tells why it failed
+// 1. decode by DecodeFunc if not nil
+// 2. verify the signature if SigningKeys is set, ErrSessionIdMalformed or ErrSessionIdSignature
+// 3. validate the session id, ErrSessionIdInvalid
+func (c *Config) DecodeSessionId(cookieValue string) (string, error) {
 	if cookieValue == "" {
-		return ""
+		return "", nil
 	}
 	decode := c.DecodeFunc
 	if decode != nil {
 		newVal, err := decode(cookieValue)
-		if err == nil {
-			cookieValue = newVal
-		} else {
-			cookieValue = ""
+		if err != nil {
+			return "", fmt.Errorf("session id decode error: %w", err)
 		}
+		cookieValue = newVal
+	}
+	if len(c.SigningKeys) > 0 {
+		sessionId, err := c.getSigner().Verify(cookieValue)
+		if err != nil {
+			return "", err
+		}
+		cookieValue = sessionId
+	}
+	if !c.SessionIdValidator(cookieValue) {
+		return "", ErrSessionIdInvalid
 	}
-	return cookieValue
+	return cookieValue, nil
 }
@@ -0,0 +1,60 @@
+package fasthttpsession
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDefaultSessionIdValidator(t *testing.T) {
+	tests := []struct {
+		sessionId string
+		want      bool
+	}{
+		{"2Ww6DJYyxhL7HV6sMqkZYQVPB3r", true},
+		{"abc_DEF-123", true},
+		{"1234567", false},
+		{"12345678", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"abc+def/ghi=", false},
+		{"abc def ghi", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := DefaultSessionIdValidator(test.sessionId); got != test.want {
+			t.Errorf("DefaultSessionIdValidator(%q) = %v, want %v", test.sessionId, got, test.want)
+		}
+	}
+}
+
+func TestConfigDecodeSessionIdValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		strict    bool
+		validator func(sessionId string) bool
+		value     string
+		wantErr   error
+	}{
+		{"custom generator ids accepted", false, nil, "q1w2+e3r/4=", nil},
+		{"short ids accepted", false, nil, "abc", nil},
+		{"strict mode default validator", true, nil, "q1w2+e3r/4=", ErrSessionIdInvalid},
+		{"strict mode valid id", true, nil, "2Ww6DJYyxhL7HV6sMqkZYQVPB3r", nil},
+		{"opt-in default validator", false, DefaultSessionIdValidator, "abc", ErrSessionIdInvalid},
+		{"strict mode custom validator", true, func(sessionId string) bool { return len(sessionId) == 3 }, "abc", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := NewDefaultConfig()
+			config.StrictSessionId = test.strict
+			config.SessionIdValidatorFunc = test.validator
+			sessionId, err := config.DecodeSessionId(test.value)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("DecodeSessionId(%q) error = %v, want %v", test.value, err, test.wantErr)
+			}
+			if err == nil && sessionId != test.value {
+				t.Fatalf("DecodeSessionId(%q) = %q", test.value, sessionId)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package fasthttpsession
+
+import (
+	"context"
+
+	"github.com/valyala/fasthttp"
+)
+
+type requestCtxKey struct{}
+
+// ContextWithRequestCtx returns a copy of parent which carries the fasthttp request ctx
+func ContextWithRequestCtx(parent context.Context, ctx *fasthttp.RequestCtx) context.Context {
+	return context.WithValue(parent, requestCtxKey{}, ctx)
+}
+
+// RequestCtxFromContext returns the fasthttp request ctx carried by ctx, if any
+func RequestCtxFromContext(ctx context.Context) (*fasthttp.RequestCtx, bool) {
+	if reqCtx, ok := ctx.(*fasthttp.RequestCtx); ok {
+		return reqCtx, true
+	}
+	reqCtx, ok := ctx.Value(requestCtxKey{}).(*fasthttp.RequestCtx)
+	return reqCtx, ok && reqCtx != nil
+}
@@ -1,11 +1,34 @@
 package fasthttpsession
 
 import (
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/valyala/fasthttp"
 )
 
+// max cookie value size, browsers drop cookies over about 4KB with their name and attributes.
+// larger values are split in chunks, name_0, name_1, ...
+const cookieChunkSize = 3800
+
+// max chunks of a cookie value, browsers keep at least 50 cookies per domain (RFC 6265),
+// the chunks leave room for the other cookies of the domain.
+const cookieMaxChunks = 10
+
+// MaxCookieValueSize is the max size of a cookie value, a larger value would exceed
+// the cookie limits of the browsers, SetWithOptions does not set it.
+const MaxCookieValueSize = cookieChunkSize * cookieMaxChunks
+
+var ErrCookieValueTooLarge = errors.New("session cookie error, value is larger than MaxCookieValueSize")
+
+// cookie name prefixes, browsers only accept them with the matching attributes
+const (
+	cookieSecurePrefix = "__Secure-"
+	cookieHostPrefix   = "__Host-"
+)
+
 func NewCookie() *Cookie {
 	return &Cookie{}
 }
@@ -13,46 +36,155 @@ func NewCookie() *Cookie {
 type Cookie struct {
 }
 
-// get cookie by name
+// CookieOptions are the attributes of a cookie set by SetWithOptions
+type CookieOptions struct {
+	// cookie domain
+	Domain string
+
+	// cookie path, default "/"
+	Path string
+
+	// cookie expires, like Config.Expires
+	//
+	//  0 means no expire, (24 years)
+	// -1 means when browser closes
+	// >0 is the time.Duration which the cookie should expire.
+	Expires time.Duration
+
+	// emit Max-Age instead of Expires for an expires > 0
+	MaxAge bool
+
+	// set the secure attribute, on TLS connections only unless the name requires it
+	Secure bool
+
+	// set the secure attribute on every connection
+	AlwaysSecure bool
+
+	// proxies trusted to tell a TLS connection for Secure, nil trusts no proxy
+	TrustedProxies *TrustedProxies
+
+	// cookie sameSite attribute
+	SameSite fasthttp.CookieSameSite
+
+	// cookie httponly attribute
+	HTTPOnly bool
+
+	// partitioned attribute (CHIPS), it implies secure
+	Partitioned bool
+}
+
+// get cookie by name, a value set in chunks is reassembled
 func (c *Cookie) Get(ctx *fasthttp.RequestCtx, name string) (value string) {
 	cookieByte := ctx.Request.Header.Cookie(name)
 	if len(cookieByte) > 0 {
-		value = string(cookieByte)
+		return string(cookieByte)
 	}
-	return
+
+	var chunks strings.Builder
+	for i := 0; ; i++ {
+		chunk := ctx.Request.Header.Cookie(chunkName(name, i))
+		if len(chunk) == 0 {
+			break
+		}
+		chunks.Write(chunk)
+	}
+	return chunks.String()
 }
 
 // response set cookie
+// a value over MaxCookieValueSize is not set, use SetWithOptions to get the error
 func (c *Cookie) Set(ctx *fasthttp.RequestCtx, name string, value string, domain string, expires time.Duration, secure bool, sameSite fasthttp.CookieSameSite, httpOnly bool) {
+	c.SetWithOptions(ctx, name, value, CookieOptions{
+		Domain:   domain,
+		Expires:  expires,
+		Secure:   secure,
+		SameSite: sameSite,
+		HTTPOnly: httpOnly,
+	})
+}
+
+// response set cookie with options
+// a value over the chunk size is split in chunks, the stale cookies of a previous value are deleted.
+// a value over MaxCookieValueSize is not set, ErrCookieValueTooLarge is returned and the
+// client keeps its previous cookie. the request must not carry more than about 4KB of
+// cookies with the fasthttp default Server.ReadBufferSize.
+// a __Secure- name is always secure, a __Host- name also gets path "/" and no domain.
+func (c *Cookie) SetWithOptions(ctx *fasthttp.RequestCtx, name string, value string, options CookieOptions) error {
+	if len(value) > MaxCookieValueSize {
+		return ErrCookieValueTooLarge
+	}
+	staleChunks := c.chunkCount(ctx, name)
+
+	if len(value) <= cookieChunkSize {
+		c.set(ctx, name, value, options)
+		for i := 0; i < staleChunks; i++ {
+			c.expire(ctx, chunkName(name, i), options)
+		}
+		return nil
+	}
+
+	if c.hasCookie(ctx, name) {
+		c.expire(ctx, name, options)
+	}
+	chunks := 0
+	for ; len(value) > 0; chunks++ {
+		size := cookieChunkSize
+		if len(value) < size {
+			size = len(value)
+		}
+		c.set(ctx, chunkName(name, chunks), value[:size], options)
+		value = value[size:]
+	}
+	for i := chunks; i < staleChunks; i++ {
+		c.expire(ctx, chunkName(name, i), options)
+	}
+	return nil
+}
+
+// delete cookie by cookie name, with all its chunks
+func (c *Cookie) Delete(ctx *fasthttp.RequestCtx, name string) {
+	c.DeleteWithOptions(ctx, name, CookieOptions{HTTPOnly: true})
+}
+
+// delete cookie by cookie name, with all its chunks
+// options must be the options of SetWithOptions, browsers only delete a cookie
+// with the same domain and path
+func (c *Cookie) DeleteWithOptions(ctx *fasthttp.RequestCtx, name string, options CookieOptions) {
+	chunks := c.chunkCount(ctx, name)
+
+	c.expire(ctx, name, options)
+	for i := 0; i < chunks; i++ {
+		c.expire(ctx, chunkName(name, i), options)
+	}
+}
+
+// response set a single cookie
+func (c *Cookie) set(ctx *fasthttp.RequestCtx, name string, value string, options CookieOptions) {
 
 	cookie := fasthttp.AcquireCookie()
 	defer fasthttp.ReleaseCookie(cookie)
 
 	cookie.SetKey(name)
-	cookie.SetPath("/")
-	cookie.SetHTTPOnly(true)
-	cookie.SetDomain(domain)
-	cookie.SetSameSite(sameSite)
-	cookie.SetHTTPOnly(httpOnly)
-	if expires >= 0 {
+	c.setAttributes(ctx, cookie, options)
+	if options.Expires >= 0 {
 		// = 0 unlimited life
 		var expiredTime time.Time = fasthttp.CookieExpireUnlimited
-		if expires > 0 {
+		if options.Expires > 0 {
 			// > 0
-			expiredTime = time.Now().Add(expires)
+			expiredTime = time.Now().Add(options.Expires)
+			if options.MaxAge {
+				cookie.SetMaxAge(int(options.Expires / time.Second))
+			}
 		}
 		cookie.SetExpire(expiredTime)
 	}
-	if ctx.IsTLS() && secure {
-		cookie.SetSecure(true)
-	}
 
 	cookie.SetValue(value)
 	ctx.Response.Header.SetCookie(cookie)
 }
 
-// delete cookie by cookie name
-func (c *Cookie) Delete(ctx *fasthttp.RequestCtx, name string) {
+// delete a single cookie from the request and the response, the client deletes it too
+func (c *Cookie) expire(ctx *fasthttp.RequestCtx, name string, options CookieOptions) {
 
 	// delete response cookie
 	ctx.Response.Header.DelCookie(name)
@@ -62,13 +194,73 @@ func (c *Cookie) Delete(ctx *fasthttp.RequestCtx, name string) {
 	defer fasthttp.ReleaseCookie(cookie)
 	cookie.SetKey(name)
 	cookie.SetValue("")
-	cookie.SetPath("/")
-	cookie.SetHTTPOnly(true)
+	c.setAttributes(ctx, cookie, options)
 	//RFC says 1 second, but let's do it 1 minute to make sure is working...
 	exp := time.Now().Add(-time.Duration(1) * time.Minute)
 	cookie.SetExpire(exp)
+	if options.MaxAge {
+		cookie.SetMaxAge(-1)
+	}
 	ctx.Response.Header.SetCookie(cookie)
 
 	// delete request's cookie also
 	ctx.Request.Header.DelCookie(name)
 }
+
+// set the cookie attributes of options, with the rules of the cookie name prefix
+func (c *Cookie) setAttributes(ctx *fasthttp.RequestCtx, cookie *fasthttp.Cookie, options CookieOptions) {
+	name := string(cookie.Key())
+	path := options.Path
+	if path == "" {
+		path = "/"
+	}
+	domain := options.Domain
+	secure := options.AlwaysSecure || (options.Secure && options.TrustedProxies.IsTLS(ctx))
+	switch {
+	case strings.HasPrefix(name, cookieHostPrefix):
+		path = "/"
+		domain = ""
+		secure = true
+	case strings.HasPrefix(name, cookieSecurePrefix):
+		secure = true
+	}
+
+	cookie.SetPath(path)
+	cookie.SetDomain(domain)
+	cookie.SetSameSite(options.SameSite)
+	cookie.SetHTTPOnly(options.HTTPOnly)
+	if secure {
+		cookie.SetSecure(true)
+	}
+	if options.Partitioned {
+		cookie.SetPartitioned(true)
+		// partitioned resets the path
+		cookie.SetPath(path)
+	}
+}
+
+// count of the chunks of name in the request or already set in the response
+func (c *Cookie) chunkCount(ctx *fasthttp.RequestCtx, name string) int {
+	chunks := 0
+	for c.hasCookie(ctx, chunkName(name, chunks)) {
+		chunks++
+	}
+	return chunks
+}
+
+// cookie name is in the request or set in the response
+func (c *Cookie) hasCookie(ctx *fasthttp.RequestCtx, name string) bool {
+	if len(ctx.Request.Header.Cookie(name)) > 0 {
+		return true
+	}
+
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey(name)
+	return ctx.Response.Header.Cookie(cookie) && len(cookie.Value()) > 0
+}
+
+// get the cookie name of chunk i, name_i
+func chunkName(name string, i int) string {
+	return name + "_" + strconv.Itoa(i)
+}
@@ -0,0 +1,74 @@
+package cookie
+
+import (
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+// session cookie config
+
+const defaultCookieName = "_fssdata_"
+
+type Config struct {
+
+	// AES keys of 16, 24 or 32 bytes, the first key encrypts and all keys decrypt.
+	// To rotate, prepend the new key and remove the old one once its cookies expired.
+	Keys [][]byte
+
+	// session data cookie name, default "_fssdata_"
+	CookieName string
+
+	// max size of the encrypted session data cookie value, default 2048 bytes,
+	// at most fasthttpsession.MaxCookieValueSize. the default fits the fasthttp
+	// default Server.ReadBufferSize of 4096 bytes, a larger size needs a larger
+	// Server.ReadBufferSize, else the server rejects the request headers.
+	MaxSize int
+
+	// session data cookie domain
+	Domain string
+
+	// session data cookie path, default "/"
+	Path string
+
+	// emit the session data cookie Max-Age attribute instead of Expires
+	MaxAge bool
+
+	// session data cookie partitioned attribute (CHIPS)
+	Partitioned bool
+
+	// session data cookie sameSite attribute
+	SameSite fasthttp.CookieSameSite
+
+	// session data cookie httponly attribute
+	HTTPOnly bool
+
+	// pass the session data cookie only through HTTPS
+	Secure bool
+
+	// set the session data cookie secure attribute on every connection
+	AlwaysSecure bool
+
+	// proxies trusted to tell a TLS connection for Secure
+	TrustedProxies *fasthttpsession.TrustedProxies
+
+	// session value serialize func
+	SerializeFunc func(data map[string]interface{}) ([]byte, error)
+
+	// session value unSerialize func
+	UnSerializeFunc func(data []byte) (map[string]interface{}, error)
+}
+
+func NewConfigWith(keys ...[]byte) (cf *Config) {
+	cf = &Config{
+		CookieName: defaultCookieName,
+		SameSite:   fasthttp.CookieSameSiteLaxMode,
+		HTTPOnly:   true,
+		Secure:     true,
+	}
+	cf.Keys = keys
+	return
+}
+
+func (cc *Config) Name() string {
+	return ProviderName
+}
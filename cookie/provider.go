@@ -0,0 +1,274 @@
+package cookie
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+// session cookie provider
+//
+// the session data is stored in the client, in a data cookie next to the session id cookie.
+// it is serialized and encrypted with AES-GCM, the session id is the additional data,
+// so the data cookie of a session is rejected for any other session.
+// a large data cookie is split in chunks by fasthttpsession.Cookie, still every request
+// carries the data, the provider is meant for small sessions. a data cookie over
+// Config.MaxSize is not written, the save returns ErrCookieTooLarge.
+
+const ProviderName = "cookie"
+
+// default max size of the data cookie value, the request headers fit
+// the fasthttp default Server.ReadBufferSize of 4096 bytes
+const defaultMaxSize = 2048
+
+var ErrCookieTooLarge = errors.New("session cookie provider error, session data is too large for a cookie")
+
+var encrypt = fasthttpsession.NewEncrypt()
+
+type Provider struct {
+	config           *Config
+	aeads            []cipher.AEAD
+	maxLifeTime      int64
+	absoluteLifeTime int64
+}
+
+// new cookie provider
+func NewProvider() *Provider {
+	return &Provider{
+		config: &Config{},
+	}
+}
+
+// init provider config
+func (cp *Provider) Init(lifeTime int64, cookieConfig fasthttpsession.ProviderConfig) error {
+	if cookieConfig.Name() != ProviderName {
+		return errors.New("session cookie provider init error, config must cookie config")
+	}
+	vc := reflect.ValueOf(cookieConfig)
+	cc := vc.Interface().(*Config)
+	cp.config = cc
+	cp.maxLifeTime = lifeTime
+
+	// config check
+	if len(cp.config.Keys) == 0 {
+		return errors.New("session cookie provider init error, config Keys not empty")
+	}
+	cp.aeads = make([]cipher.AEAD, 0, len(cp.config.Keys))
+	for _, key := range cp.config.Keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return errors.New("session cookie provider init error, " + err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return errors.New("session cookie provider init error, " + err.Error())
+		}
+		cp.aeads = append(cp.aeads, aead)
+	}
+	if cp.config.CookieName == "" {
+		cp.config.CookieName = defaultCookieName
+	}
+	if cp.config.MaxSize <= 0 {
+		cp.config.MaxSize = defaultMaxSize
+	}
+	if cp.config.MaxSize > fasthttpsession.MaxCookieValueSize {
+		return fmt.Errorf("session cookie provider init error, config MaxSize must be at most %d", fasthttpsession.MaxCookieValueSize)
+	}
+	// init config serialize func
+	if cp.config.SerializeFunc == nil {
+		cp.config.SerializeFunc = encrypt.GobEncode
+	}
+	if cp.config.UnSerializeFunc == nil {
+		cp.config.UnSerializeFunc = encrypt.GobDecode
+	}
+	return nil
+}
+
+// set the absolute session lifetime(s), 0 means no limit
+func (cp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	cp.absoluteLifeTime = absoluteLifeTime
+}
+
+// not need gc
+func (cp *Provider) NeedGC() bool {
+	return false
+}
+
+// session cookie provider not need garbage collection,
+// an expired data cookie is dropped by the client and rejected when read
+func (cp *Provider) GC() {}
+
+// read session store by session id
+// without a request the data cookie can not be read, the store is empty
+func (cp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return cp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+// ctx must carry the request, see fasthttpsession.ContextWithRequestCtx
+func (cp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
+	reqCtx, ok := fasthttpsession.RequestCtxFromContext(ctx)
+	if !ok {
+		return NewCookieStore(cp, sessionId), nil
+	}
+
+	data, size, err := cp.readDataCookie(reqCtx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return NewCookieStore(cp, sessionId), nil
+	}
+	store := NewCookieStoreData(cp, sessionId, data)
+	store.SetSize(size)
+	return store, nil
+}
+
+// regenerate session
+func (cp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return cp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+// the data of the old session is kept, it is encrypted for the new session id on save
+func (cp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	reqCtx, ok := fasthttpsession.RequestCtxFromContext(ctx)
+	if !ok {
+		return NewCookieStore(cp, sessionId), nil
+	}
+
+	data, size, err := cp.readDataCookie(reqCtx, oldSessionId)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return NewCookieStore(cp, sessionId), nil
+	}
+	store := NewCookieStoreData(cp, sessionId, data)
+	store.SetSize(size)
+	return store, nil
+}
+
+// destroy session by sessionId
+func (cp *Provider) Destroy(sessionId string) error {
+	return cp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context, the data cookie is deleted
+func (cp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	reqCtx, ok := fasthttpsession.RequestCtxFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	fasthttpsession.NewCookie().DeleteWithOptions(reqCtx, cp.config.CookieName, cp.cookieOptions(0))
+	return nil
+}
+
+// session exists by sessionId, the request carries a valid data cookie of the session
+func (cp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	reqCtx, ok := fasthttpsession.RequestCtxFromContext(ctx)
+	if !ok {
+		return false, errors.New("session cookie provider exists error, context carries no request")
+	}
+	value := fasthttpsession.NewCookie().Get(reqCtx, cp.config.CookieName)
+	if value == "" {
+		return false, nil
+	}
+	_, ok = cp.decrypt([]byte(value), sessionId)
+	return ok, nil
+}
+
+// session values count
+// the sessions live in the clients, they can not be counted
+func (cp *Provider) Count() int {
+	return 0
+}
+
+// data cookie options of the config
+func (cp *Provider) cookieOptions(expires time.Duration) fasthttpsession.CookieOptions {
+	return fasthttpsession.CookieOptions{
+		Domain:         cp.config.Domain,
+		Path:           cp.config.Path,
+		Expires:        expires,
+		MaxAge:         cp.config.MaxAge,
+		Secure:         cp.config.Secure,
+		AlwaysSecure:   cp.config.AlwaysSecure,
+		TrustedProxies: cp.config.TrustedProxies,
+		SameSite:       cp.config.SameSite,
+		HTTPOnly:       cp.config.HTTPOnly,
+		Partitioned:    cp.config.Partitioned,
+	}
+}
+
+// read and decrypt the data cookie of sessionId,
+// nil data if there is no valid data cookie for the session, size is the serialized data size
+func (cp *Provider) readDataCookie(ctx *fasthttp.RequestCtx, sessionId string) (map[string]interface{}, int, error) {
+	value := fasthttpsession.NewCookie().Get(ctx, cp.config.CookieName)
+	if value == "" {
+		return nil, 0, nil
+	}
+	plaintext, ok := cp.decrypt([]byte(value), sessionId)
+	if !ok {
+		return nil, 0, nil
+	}
+	data, err := cp.config.UnSerializeFunc(plaintext)
+	return data, len(plaintext), err
+}
+
+// encrypt the session data of sessionId expiring after lifeTime(s)
+// value: base64(nonce | AES-GCM(expire time | data))
+func (cp *Provider) encrypt(sessionId string, data []byte, lifeTime int64) ([]byte, error) {
+	aead := cp.aeads[0]
+
+	plaintext := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(plaintext, uint64(time.Now().Unix()+lifeTime))
+	copy(plaintext[8:], data)
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(sessionId))
+
+	value := make([]byte, base64.RawURLEncoding.EncodedLen(len(sealed)))
+	base64.RawURLEncoding.Encode(value, sealed)
+	return value, nil
+}
+
+// decrypt the value encrypted for sessionId, ok is false if no key opens it or it expired
+func (cp *Provider) decrypt(value []byte, sessionId string) ([]byte, bool) {
+	sealed := make([]byte, base64.RawURLEncoding.DecodedLen(len(value)))
+	n, err := base64.RawURLEncoding.Decode(sealed, value)
+	if err != nil {
+		return nil, false
+	}
+	sealed = sealed[:n]
+
+	for _, aead := range cp.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(sessionId))
+		if err != nil || len(plaintext) < 8 {
+			continue
+		}
+		expireTime := int64(binary.BigEndian.Uint64(plaintext))
+		if time.Now().Unix() >= expireTime {
+			return nil, false
+		}
+		return plaintext[8:], true
+	}
+	return nil, false
+}
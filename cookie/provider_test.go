@@ -0,0 +1,82 @@
+package cookie
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func TestInitMaxSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int
+		want    int
+		wantErr bool
+	}{
+		{"default", 0, defaultMaxSize, false},
+		{"custom", 8192, 8192, false},
+		{"browser limit", fasthttpsession.MaxCookieValueSize, fasthttpsession.MaxCookieValueSize, false},
+		{"over the browser limit", fasthttpsession.MaxCookieValueSize + 1, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := NewConfigWith(testKey)
+			config.MaxSize = test.maxSize
+			err := NewProvider().Init(60, config)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("Init accepted a MaxSize over the browser limit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.MaxSize != test.want {
+				t.Fatalf("MaxSize = %d, want %d", config.MaxSize, test.want)
+			}
+		})
+	}
+}
+
+func TestSaveMaxSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr error
+	}{
+		{"small session", 100, nil},
+		{"session over MaxSize", 4096, ErrCookieTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := NewProvider()
+			if err := provider.Init(60, NewConfigWith(testKey)); err != nil {
+				t.Fatal(err)
+			}
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Init(&fasthttp.Request{}, nil, nil)
+			c := fasthttpsession.ContextWithRequestCtx(context.Background(), ctx)
+
+			sessionStore, err := provider.ReadStoreContext(c, "sessionId")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionStore.Set("data", strings.Repeat("d", test.size))
+			err = sessionStore.(*Store).SaveContext(c)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SaveContext error = %v, want %v", err, test.wantErr)
+			}
+			written := len(ctx.Response.Header.PeekCookie(defaultCookieName)) > 0
+			if written != (test.wantErr == nil) {
+				t.Fatalf("data cookie written = %v, want %v", written, test.wantErr == nil)
+			}
+		})
+	}
+}
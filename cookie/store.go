@@ -0,0 +1,73 @@
+package cookie
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+// session cookie store
+
+// new default cookie store
+func NewCookieStore(provider *Provider, sessionId string) *Store {
+	cookieStore := &Store{provider: provider}
+	cookieStore.Init(sessionId, make(map[string]interface{}))
+	return cookieStore
+}
+
+// new cookie store data
+func NewCookieStoreData(provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	cookieStore := &Store{provider: provider}
+	cookieStore.Init(sessionId, data)
+	return cookieStore
+}
+
+type Store struct {
+	fasthttpsession.Store
+	provider *Provider
+}
+
+// save store
+func (cs *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return cs.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+// ctx must carry the request, the data cookie is always written again to refresh its expiry
+func (cs *Store) SaveContext(ctx context.Context) error {
+	reqCtx, ok := fasthttpsession.RequestCtxFromContext(ctx)
+	if !ok {
+		return errors.New("session cookie store save error, context carries no request")
+	}
+
+	b, err := cs.provider.config.SerializeFunc(cs.Export())
+	if err != nil {
+		return err
+	}
+	lifeTime := cs.Lifetime(cs.provider.maxLifeTime, cs.provider.absoluteLifeTime)
+	value, err := cs.provider.encrypt(cs.GetSessionId(), b, lifeTime)
+	if err != nil {
+		return err
+	}
+	cookieName := cs.provider.config.CookieName
+	if len(value) > cs.provider.config.MaxSize {
+		return ErrCookieTooLarge
+	}
+
+	err = fasthttpsession.NewCookie().SetWithOptions(reqCtx, cookieName, string(value), cs.provider.cookieOptions(time.Duration(lifeTime)*time.Second))
+	if errors.Is(err, fasthttpsession.ErrCookieValueTooLarge) {
+		return ErrCookieTooLarge
+	}
+	if err != nil {
+		return err
+	}
+	// a session read again by the request gets the saved data
+	reqCtx.Request.Header.SetCookieBytesKV([]byte(cookieName), value)
+
+	cs.MarkSaved()
+	cs.SetSize(len(b))
+	return nil
+}
@@ -0,0 +1,172 @@
+package fasthttpsession
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// response cookies by name, with their value and whether they delete the cookie
+func responseCookies(t *testing.T, ctx *fasthttp.RequestCtx) map[string]*fasthttp.Cookie {
+	t.Helper()
+	cookies := map[string]*fasthttp.Cookie{}
+	ctx.Response.Header.VisitAllCookie(func(key, value []byte) {
+		cookie := &fasthttp.Cookie{}
+		if err := cookie.ParseBytes(value); err != nil {
+			t.Fatal(err)
+		}
+		cookies[string(key)] = cookie
+	})
+	return cookies
+}
+
+// new request ctx carrying the cookies
+func newCookieCtx(cookies map[string]string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&fasthttp.Request{}, nil, nil)
+	for name, value := range cookies {
+		ctx.Request.Header.SetCookie(name, value)
+	}
+	return ctx
+}
+
+func TestCookieChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"single cookie", cookieChunkSize, 0},
+		{"two chunks", cookieChunkSize + 1, 2},
+		{"three chunks", 3 * cookieChunkSize, 3},
+		{"max size", MaxCookieValueSize, cookieMaxChunks},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := strings.Repeat("v", test.size)
+			ctx := newCookieCtx(nil)
+			if err := NewCookie().SetWithOptions(ctx, "name", value, CookieOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			cookies := responseCookies(t, ctx)
+			want := test.chunks
+			if want == 0 {
+				want = 1
+			}
+			if len(cookies) != want {
+				t.Fatalf("%d cookies set, want %d", len(cookies), want)
+			}
+			request := map[string]string{}
+			for name, cookie := range cookies {
+				if len(cookie.Value()) > cookieChunkSize {
+					t.Fatalf("cookie %s of %d bytes, over the chunk size", name, len(cookie.Value()))
+				}
+				request[name] = string(cookie.Value())
+			}
+			if got := NewCookie().Get(newCookieCtx(request), "name"); got != value {
+				t.Fatalf("reassembled value of %d bytes, want %d bytes", len(got), len(value))
+			}
+		})
+	}
+}
+
+func TestCookieTooLarge(t *testing.T) {
+	ctx := newCookieCtx(nil)
+	err := NewCookie().SetWithOptions(ctx, "name", strings.Repeat("v", MaxCookieValueSize+1), CookieOptions{})
+	if !errors.Is(err, ErrCookieValueTooLarge) {
+		t.Fatalf("SetWithOptions error = %v, want ErrCookieValueTooLarge", err)
+	}
+	if cookies := responseCookies(t, ctx); len(cookies) != 0 {
+		t.Fatalf("%d cookies set for a value over MaxCookieValueSize", len(cookies))
+	}
+}
+
+func TestCookieStaleChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		request map[string]string
+		value   string
+		set     []string
+		expired []string
+	}{
+		{
+			name:    "chunks replaced by a single cookie",
+			request: map[string]string{"name_0": "a", "name_1": "b", "name_2": "c"},
+			value:   "small",
+			set:     []string{"name"},
+			expired: []string{"name_0", "name_1", "name_2"},
+		},
+		{
+			name:    "single cookie replaced by chunks",
+			request: map[string]string{"name": "small"},
+			value:   strings.Repeat("v", cookieChunkSize+1),
+			set:     []string{"name_0", "name_1"},
+			expired: []string{"name"},
+		},
+		{
+			name:    "fewer chunks",
+			request: map[string]string{"name_0": "a", "name_1": "b", "name_2": "c"},
+			value:   strings.Repeat("v", cookieChunkSize+1),
+			set:     []string{"name_0", "name_1"},
+			expired: []string{"name_2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := newCookieCtx(test.request)
+			NewCookie().SetWithOptions(ctx, "name", test.value, CookieOptions{})
+			checkCookies(t, responseCookies(t, ctx), test.set, test.expired)
+		})
+	}
+}
+
+func TestCookieDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		request map[string]string
+		expired []string
+	}{
+		{"single cookie", map[string]string{"name": "value"}, []string{"name"}},
+		{"chunks", map[string]string{"name_0": "a", "name_1": "b"}, []string{"name", "name_0", "name_1"}},
+		{"other cookies kept", map[string]string{"name": "value", "other": "value"}, []string{"name"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := newCookieCtx(test.request)
+			NewCookie().DeleteWithOptions(ctx, "name", CookieOptions{Path: "/app"})
+			cookies := responseCookies(t, ctx)
+			checkCookies(t, cookies, nil, test.expired)
+			for _, name := range test.expired {
+				if path := string(cookies[name].Path()); path != "/app" {
+					t.Fatalf("cookie %s deleted with path %s, want /app", name, path)
+				}
+				if len(ctx.Request.Header.Cookie(name)) > 0 {
+					t.Fatalf("cookie %s is still in the request", name)
+				}
+			}
+		})
+	}
+}
+
+// check the response cookies set and expired, and that there are no others
+func checkCookies(t *testing.T, cookies map[string]*fasthttp.Cookie, set []string, expired []string) {
+	t.Helper()
+	if len(cookies) != len(set)+len(expired) {
+		t.Fatalf("%d cookies in the response, want %d", len(cookies), len(set)+len(expired))
+	}
+	for _, name := range set {
+		cookie, ok := cookies[name]
+		if !ok || len(cookie.Value()) == 0 {
+			t.Fatalf("cookie %s is not set", name)
+		}
+	}
+	for _, name := range expired {
+		cookie, ok := cookies[name]
+		if !ok || len(cookie.Value()) != 0 {
+			t.Fatalf("cookie %s is not deleted", name)
+		}
+	}
+}
@@ -16,6 +16,12 @@ const (
 	BASE64TABLE = "1234567890poiuytreqwasdfghjklmnbvcxzQWERTYUIOPLKJHGFDSAZXCVBNM-_"
 )
 
+func init() {
+	// flash messages are stored as []interface{}, the session meta data as map[string]interface{}
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
 func NewEncrypt() *encrypt {
 	return &encrypt{}
 }
@@ -45,6 +51,11 @@ func (s *encrypt) GobEncode(data map[string]interface{}) ([]byte, error) {
 	}
 	for _, v := range data {
 		gob.Register(v)
+		if values, ok := v.([]interface{}); ok {
+			for _, value := range values {
+				gob.Register(value)
+			}
+		}
 	}
 	buf := bytes.NewBuffer(nil)
 	enc := gob.NewEncoder(buf)
@@ -0,0 +1,143 @@
+package fasthttpsession
+
+import (
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Extractor gets the session id value sent by the client, empty if there is none.
+// the value is decoded by Config.DecodeSessionId.
+type Extractor interface {
+	Extract(ctx *fasthttp.RequestCtx) string
+}
+
+// Injector writes the session id value back to the client, and removes it on destroy.
+// the value is encoded by Config.EncodeSessionId,
+// expires is the session cookie expiry, like Config.Expires.
+type Injector interface {
+	Inject(ctx *fasthttp.RequestCtx, value string, expires time.Duration)
+	Remove(ctx *fasthttp.RequestCtx)
+}
+
+// cookie extractor and injector
+type CookieExtractor struct {
+	name    string
+	options CookieOptions
+	cookie  *Cookie
+}
+
+// return new cookie extractor and injector of the cookie name,
+// options are the cookie attributes, Expires is set by the session
+func NewCookieExtractor(name string, options CookieOptions) *CookieExtractor {
+	return &CookieExtractor{
+		name:    name,
+		options: options,
+		cookie:  NewCookie(),
+	}
+}
+
+// get the cookie value
+func (ce *CookieExtractor) Extract(ctx *fasthttp.RequestCtx) string {
+	return ce.cookie.Get(ctx, ce.name)
+}
+
+// set the response cookie
+func (ce *CookieExtractor) Inject(ctx *fasthttp.RequestCtx, value string, expires time.Duration) {
+	options := ce.options
+	options.Expires = expires
+	// a session id is far below MaxCookieValueSize
+	ce.cookie.SetWithOptions(ctx, ce.name, value, options)
+}
+
+// delete the cookie, with the attributes it was set with
+func (ce *CookieExtractor) Remove(ctx *fasthttp.RequestCtx) {
+	ce.cookie.DeleteWithOptions(ctx, ce.name, ce.options)
+}
+
+// http header extractor and injector
+type HeaderExtractor struct {
+	name string
+}
+
+// return new http header extractor and injector of the header name
+func NewHeaderExtractor(name string) *HeaderExtractor {
+	return &HeaderExtractor{name: name}
+}
+
+// get the request header value
+func (he *HeaderExtractor) Extract(ctx *fasthttp.RequestCtx) string {
+	return string(ctx.Request.Header.Peek(he.name))
+}
+
+// set the request and response header, the request header is read again by this request
+func (he *HeaderExtractor) Inject(ctx *fasthttp.RequestCtx, value string, expires time.Duration) {
+	ctx.Request.Header.Set(he.name, value)
+	ctx.Response.Header.Set(he.name, value)
+}
+
+// delete the request and response header
+func (he *HeaderExtractor) Remove(ctx *fasthttp.RequestCtx) {
+	ctx.Request.Header.Del(he.name)
+	ctx.Response.Header.Del(he.name)
+}
+
+// url query extractor
+type QueryExtractor struct {
+	name string
+}
+
+// return new url query extractor of the query arg name
+func NewQueryExtractor(name string) *QueryExtractor {
+	return &QueryExtractor{name: name}
+}
+
+// get the query arg value
+func (qe *QueryExtractor) Extract(ctx *fasthttp.RequestCtx) string {
+	return string(ctx.QueryArgs().Peek(qe.name))
+}
+
+// form field extractor, urlencoded or multipart post form
+type FormExtractor struct {
+	name string
+}
+
+// return new form field extractor of the field name
+func NewFormExtractor(name string) *FormExtractor {
+	return &FormExtractor{name: name}
+}
+
+// get the post form field value
+func (fe *FormExtractor) Extract(ctx *fasthttp.RequestCtx) string {
+	if value := ctx.PostArgs().Peek(fe.name); len(value) > 0 {
+		return string(value)
+	}
+	form, err := ctx.MultipartForm()
+	if err != nil {
+		return ""
+	}
+	if values := form.Value[fe.name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Authorization: Bearer <token> extractor
+type BearerExtractor struct {
+}
+
+// return new bearer token extractor
+func NewBearerExtractor() *BearerExtractor {
+	return &BearerExtractor{}
+}
+
+// get the bearer token of the Authorization header
+func (be *BearerExtractor) Extract(ctx *fasthttp.RequestCtx) string {
+	authorization := string(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization))
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
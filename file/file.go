@@ -9,6 +9,17 @@ import (
 
 type file struct{}
 
+// provider dirs inside the save path, they hold no session files
+var reservedDirs = map[string]bool{
+	lockDirName: true,
+	userDirName: true,
+}
+
+// filename is a reserved dir of the save path dirPth
+func (f *file) isReservedDir(dirPth, filename string, fi os.FileInfo) bool {
+	return fi.IsDir() && reservedDirs[fi.Name()] && filepath.Dir(filename) == filepath.Clean(dirPth)
+}
+
 // create file
 func (f *file) createFile(filename string) error {
 	newFile, err := os.Create(filename)
@@ -36,8 +47,7 @@ func (f *file) getContent(filename string) (data []byte, err error) {
 	}
 	defer fi.Close()
 
-	fd, err := ioutil.ReadAll(fi)
-	return fd, nil
+	return ioutil.ReadAll(fi)
 }
 
 // get file update time
@@ -57,6 +67,9 @@ func (f *file) walkDir(dirPth, suffix string) (files []string, err error) {
 		if err != nil {
 			return err
 		}
+		if f.isReservedDir(dirPth, filename, fi) {
+			return filepath.SkipDir
+		}
 		if fi.IsDir() {
 			return nil
 		}
@@ -82,6 +95,9 @@ func (f *file) count(dirPth, suffix string) (total int, err error) {
 		if err != nil {
 			return err
 		}
+		if f.isReservedDir(dirPth, filename, fi) {
+			return filepath.SkipDir
+		}
 		if fi.IsDir() {
 			return nil
 		}
@@ -0,0 +1,93 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/brunohass/fasthttpsession"
+)
+
+// lock session by sessionId with flock on its lock file,
+// the lock is shared by all the processes using the save path
+func (fp *Provider) lockSession(ctx context.Context, sessionId string) (func() error, error) {
+	lockFileName := fp.getSessionLockFile(sessionId)
+
+	var lockFile *os.File
+	err := fasthttpsession.WaitLock(ctx, func() (bool, error) {
+		var err error
+		lockFile, err = tryLockFile(lockFileName, true)
+		return lockFile != nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		defer lockFile.Close()
+		return syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+	}, nil
+}
+
+// open and flock the lock file without waiting, nil if it is locked by another request.
+// a lock file removed by GC since it was opened is not the lock any more, it is not returned either.
+func tryLockFile(lockFileName string, create bool) (*os.File, error) {
+	flag := os.O_RDWR
+	if create {
+		os.MkdirAll(filepath.Dir(lockFileName), 0777)
+		flag |= os.O_CREATE
+	}
+	lockFile, err := os.OpenFile(lockFileName, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK || err == syscall.EINTR {
+		lockFile.Close()
+		return nil, nil
+	}
+	if err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+	lockInfo, err := lockFile.Stat()
+	if err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+	pathInfo, err := os.Stat(lockFileName)
+	if err != nil || !os.SameFile(lockInfo, pathInfo) {
+		lockFile.Close()
+		return nil, nil
+	}
+	return lockFile, nil
+}
+
+// remove the lock files of the sessions which have no session file,
+// a lock file is only removed while its flock is taken, a locked or waited for session keeps it
+func (fp *Provider) removeLockFiles() {
+	lockFiles, err := filepath.Glob(filepath.Join(fp.config.SavePath, lockDirName, "*.lock"))
+	if err != nil {
+		fp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		return
+	}
+	for _, lockFileName := range lockFiles {
+		sessionId := strings.TrimSuffix(filepath.Base(lockFileName), ".lock")
+		if checkSessionId(sessionId) == nil {
+			_, _, fullFileName := fp.getSessionFile(sessionId)
+			if fp.file.pathIsExists(fullFileName) {
+				continue
+			}
+		}
+		lockFile, err := tryLockFile(lockFileName, false)
+		if err != nil || lockFile == nil {
+			continue
+		}
+		os.Remove(lockFileName)
+		lockFile.Close()
+	}
+}
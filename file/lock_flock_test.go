@@ -0,0 +1,77 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package file
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLockFileCleanup(t *testing.T) {
+	tests := []struct {
+		name        string
+		saved       bool
+		destroyed   bool
+		unlocked    bool
+		wantRemoved bool
+	}{
+		{"destroyed while locked", true, true, false, false},
+		{"destroyed and unlocked", true, true, true, true},
+		{"saved session", true, false, true, false},
+		{"never saved session", false, false, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestProvider(t, 60)
+			if test.saved {
+				saveTestSession(t, provider, "s1", map[string]interface{}{"a": "a"})
+			}
+			unlock, err := provider.Lock(context.Background(), "s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.destroyed {
+				if err := provider.Destroy("s1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			lockFileName := provider.getSessionLockFile("s1")
+			if !provider.file.pathIsExists(lockFileName) {
+				t.Fatal("lock file removed while the session is locked")
+			}
+			if test.unlocked {
+				if err := unlock(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			provider.GC()
+			if removed := !provider.file.pathIsExists(lockFileName); removed != test.wantRemoved {
+				t.Fatalf("lock file removed = %v, want %v", removed, test.wantRemoved)
+			}
+
+			// the session can be locked again
+			if !test.unlocked {
+				unlock()
+			}
+			unlock, err = provider.Lock(context.Background(), "s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			unlock()
+		})
+	}
+}
+
+func TestTryLockFileHeld(t *testing.T) {
+	provider := newTestProvider(t, 60)
+	lockFileName := provider.getSessionLockFile("s1")
+	holder, err := tryLockFile(lockFileName, true)
+	if err != nil || holder == nil {
+		t.Fatalf("tryLockFile = %v, %v", holder, err)
+	}
+	defer holder.Close()
+	if lockFile, err := tryLockFile(lockFileName, true); err != nil || lockFile != nil {
+		t.Fatalf("tryLockFile of a held lock = %v, %v, want nil", lockFile, err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package file
+
+import (
+	"context"
+)
+
+// lock session by sessionId
+// flock is not available, the lock only covers the requests of this process
+func (fp *Provider) lockSession(ctx context.Context, sessionId string) (func() error, error) {
+	return fp.locker.Lock(ctx, sessionId)
+}
+
+// no lock files without flock
+func (fp *Provider) removeLockFiles() {}
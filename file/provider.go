@@ -1,12 +1,16 @@
 package file
 
 import (
+	"context"
+	"crypto/sha1"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,23 +22,35 @@ import (
 
 const ProviderName = "file"
 
-var (
-	fileProvider = NewProvider()
-	encrypt      = fasthttpsession.NewEncrypt()
-)
+// dir of the session lock files inside the save path
+const lockDirName = "_lock"
+
+// dir of the user id index inside the save path, a dir of session id files per user
+const userDirName = "_user"
+
+var encrypt = fasthttpsession.NewEncrypt()
+
+// the session id can not be used as a session file name
+var ErrInvalidSessionId = errors.New("session file provider error, invalid session id")
 
 type Provider struct {
-	lock        sync.RWMutex
-	file        *file
-	config      *Config
-	maxLifeTime int64
+	lock             sync.RWMutex
+	file             *file
+	locker           *fasthttpsession.SessionLocker
+	config           *Config
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	expireFunc       func(sessionId string)
+	logger           fasthttpsession.Logger
 }
 
 // new file provider
 func NewProvider() *Provider {
 	return &Provider{
 		file:   &file{},
+		locker: fasthttpsession.NewSessionLocker(),
 		config: &Config{},
+		logger: fasthttpsession.NewNopLogger(),
 	}
 }
 
@@ -61,11 +77,29 @@ func (fp *Provider) Init(lifeTime int64, fileConfig fasthttpsession.ProviderConf
 	fp.maxLifeTime = lifeTime
 
 	// create save path
-	os.MkdirAll(fp.config.SavePath, 0777)
+	err := os.MkdirAll(fp.config.SavePath, 0777)
+	if err != nil {
+		return errors.New("session file provider init error, " + err.Error())
+	}
 
 	return nil
 }
 
+// set the absolute session lifetime(s), 0 means no limit
+func (fp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	fp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the func called with the sessions expired by GC
+func (fp *Provider) SetExpireFunc(expireFunc func(sessionId string)) {
+	fp.expireFunc = expireFunc
+}
+
+// set the logger of the failures which can not be returned
+func (fp *Provider) SetLogger(logger fasthttpsession.Logger) {
+	fp.logger = logger
+}
+
 // need gc
 func (fp *Provider) NeedGC() bool {
 	return true
@@ -75,31 +109,58 @@ func (fp *Provider) NeedGC() bool {
 func (fp *Provider) GC() {
 
 	files, err := fp.file.walkDir(fp.config.SavePath, fp.config.Suffix)
+	if err != nil {
+		fp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+	}
 	if err == nil {
 		for _, file := range files {
-			if time.Now().Unix() >= (fp.maxLifeTime + fp.file.getModifyTime(file)) {
+			store := fp.getSessionMeta(file)
+			expired := time.Now().Unix() >= (store.IdleLifetime(fp.maxLifeTime) + fp.file.getModifyTime(file))
+			if !expired && fp.absoluteLifeTime > 0 {
+				expired = time.Now().Unix() >= (fp.absoluteLifeTime + store.CreatedAt().Unix())
+			}
+			if expired {
 				fp.lock.Lock()
 				filename := filepath.Base(file)
-				sessionId := strings.TrimRight(filename, fp.config.Suffix)
-				fp.removeSessionFile(sessionId)
+				sessionId := strings.TrimSuffix(filename, fp.config.Suffix)
+				err := fp.removeSessionFile(sessionId)
 				fp.lock.Unlock()
+				if err != nil {
+					fp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "sessionId", sessionId, "error", err)
+					continue
+				}
+				if fp.expireFunc != nil {
+					fp.expireFunc(sessionId)
+				}
 			}
 		}
 	}
+	fp.removeLockFiles()
 }
 
 // read session store by session id
 func (fp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return fp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+func (fp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkSessionId(sessionId); err != nil {
+		return nil, err
+	}
 
 	fp.lock.Lock()
 	defer fp.lock.Unlock()
-	store := &Store{}
+	store := &Store{provider: fp}
 
 	filePath, _, fullFileName := fp.getSessionFile(sessionId)
 
 	// file is exist
 	if fp.file.pathIsExists(fullFileName) {
-		sessionInfo, err := fp.file.getContent(fullFileName)
+		sessionInfo, err := fp.readFile(ctx, fullFileName)
 		if err != nil {
 			return store, err
 		}
@@ -110,13 +171,16 @@ func (fp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 			return store, err
 		}
 		store.Init(sessionId, value)
+		store.SetSize(len(sessionInfo))
 
 		return store, nil
 	}
 
-	os.MkdirAll(filePath, 0777)
-
-	err := fp.file.createFile(fullFileName)
+	err := os.MkdirAll(filePath, 0777)
+	if err != nil {
+		return store, err
+	}
+	err = fp.file.createFile(fullFileName)
 	if err != nil {
 		return store, err
 	}
@@ -127,10 +191,24 @@ func (fp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 
 // regenerate session
 func (fp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return fp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+func (fp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkSessionId(oldSessionId); err != nil {
+		return nil, err
+	}
+	if err := checkSessionId(sessionId); err != nil {
+		return nil, err
+	}
 
 	fp.lock.Lock()
 	defer fp.lock.Unlock()
-	store := &Store{}
+	store := &Store{provider: fp}
 
 	_, _, oldFullFileName := fp.getSessionFile(oldSessionId)
 	filePath, _, fullFileName := fp.getSessionFile(sessionId)
@@ -139,24 +217,36 @@ func (fp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttps
 		return store, errors.New("new sessionId file exist")
 	}
 	// create new session file
-	os.MkdirAll(filePath, 0777)
-	err := fp.file.createFile(fullFileName)
+	err := os.MkdirAll(filePath, 0777)
+	if err != nil {
+		return store, err
+	}
+	err = fp.file.createFile(fullFileName)
 	if err != nil {
 		return store, err
 	}
 
 	if fp.file.pathIsExists(oldFullFileName) {
 		// read old session info
-		sessionInfo, err := fp.file.getContent(fullFileName)
+		sessionInfo, err := fp.readFile(ctx, oldFullFileName)
 		if err != nil {
 			return store, err
 		}
 		// write new session file
-		ioutil.WriteFile(fullFileName, sessionInfo, 0777)
+		err = fp.writeFile(ctx, fullFileName, sessionInfo)
+		if err != nil {
+			return store, err
+		}
 		// remove old session file
-		fp.removeSessionFile(oldSessionId)
+		err = fp.removeSessionFile(oldSessionId)
+		if err != nil {
+			return store, err
+		}
 		// update new session file time
-		os.Chtimes(fullFileName, time.Now(), time.Now())
+		err = os.Chtimes(fullFileName, time.Now(), time.Now())
+		if err != nil {
+			return store, err
+		}
 
 		// unserialize sessionInfo
 		value, err := fp.config.UnSerializeFunc(sessionInfo)
@@ -164,6 +254,7 @@ func (fp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttps
 			return store, err
 		}
 		store.Init(sessionId, value)
+		store.SetSize(len(sessionInfo))
 
 		return store, nil
 	}
@@ -175,18 +266,130 @@ func (fp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttps
 
 // destroy session by sessionId
 func (fp *Provider) Destroy(sessionId string) error {
+	return fp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (fp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkSessionId(sessionId); err != nil {
+		return err
+	}
 
 	fp.lock.Lock()
 	defer fp.lock.Unlock()
 
 	_, _, fullFileName := fp.getSessionFile(sessionId)
 	if fp.file.pathIsExists(fullFileName) {
-		fp.removeSessionFile(sessionId)
+		return fp.removeSessionFile(sessionId)
 	}
 
 	return nil
 }
 
+// session ids of userID
+// the ids of expired sessions or sessions given to another user are removed from the index
+func (fp *Provider) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+
+	return fp.userSessions(userID)
+}
+
+// destroy all the sessions of userID
+func (fp *Provider) DestroyUserSessions(ctx context.Context, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+
+	sessionIds, err := fp.userSessions(userID)
+	if err != nil {
+		return err
+	}
+	userDir := fp.getUserIndexDir(userID)
+	for _, sessionId := range sessionIds {
+		err = fp.removeSessionFile(sessionId)
+		if err != nil {
+			return err
+		}
+		os.Remove(filepath.Join(userDir, sessionId))
+	}
+	os.Remove(userDir)
+	return nil
+}
+
+// scan the sessions in session id order, the cursor is the last session id
+func (fp *Provider) Scan(ctx context.Context, cursor string, limit int) ([]fasthttpsession.SessionInfo, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	fp.lock.RLock()
+	defer fp.lock.RUnlock()
+
+	files, err := fp.file.walkDir(fp.config.SavePath, fp.config.Suffix)
+	if err != nil {
+		return nil, "", err
+	}
+	sessionIds := make([]string, 0, len(files))
+	for _, file := range files {
+		filename := filepath.Base(file)
+		sessionId := filename[:len(filename)-len(fp.config.Suffix)]
+		if sessionId > cursor {
+			sessionIds = append(sessionIds, sessionId)
+		}
+	}
+	sort.Strings(sessionIds)
+
+	infos := make([]fasthttpsession.SessionInfo, 0, limit)
+	for _, sessionId := range sessionIds {
+		info, _, err := fp.peek(sessionId)
+		if err == fasthttpsession.ErrSessionNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		// expired, not collected by the gc yet
+		idleLifeTime := int64(info.TTL / time.Second)
+		if idleLifeTime == 0 {
+			idleLifeTime = fp.maxLifeTime
+		}
+		if time.Now().Unix() >= info.LastActiveAt.Unix()+idleLifeTime {
+			continue
+		}
+		infos = append(infos, info)
+		if len(infos) == limit {
+			return infos, sessionId, nil
+		}
+	}
+	return infos, "", nil
+}
+
+// peek the session data, the file time is not updated
+func (fp *Provider) Peek(ctx context.Context, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	if err := checkSessionId(sessionId); err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+
+	fp.lock.RLock()
+	defer fp.lock.RUnlock()
+
+	return fp.peek(sessionId)
+}
+
 // session values count
 func (fp *Provider) Count() int {
 	fp.lock.Lock()
@@ -197,7 +400,66 @@ func (fp *Provider) Count() int {
 	return count
 }
 
+// session exists by sessionId
+func (fp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	if err := checkSessionId(sessionId); err != nil {
+		return false, err
+	}
+
+	fp.lock.RLock()
+	defer fp.lock.RUnlock()
+
+	_, _, fullFileName := fp.getSessionFile(sessionId)
+	return fp.file.pathIsExists(fullFileName), nil
+}
+
+// lock session by sessionId
+func (fp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	if err := checkSessionId(sessionId); err != nil {
+		return nil, err
+	}
+	return fp.lockSession(ctx, sessionId)
+}
+
+// get the session file meta data (create time, TTL, version), as a store
+func (fp *Provider) getSessionMeta(filename string) *Store {
+	fp.lock.RLock()
+	sessionInfo, err := fp.file.getContent(filename)
+	fp.lock.RUnlock()
+	if err != nil {
+		sessionInfo = nil
+	}
+	return fp.unSerializeMeta(sessionInfo)
+}
+
+// get the meta data of the session file content, as a store
+func (fp *Provider) unSerializeMeta(sessionInfo []byte) *Store {
+	store := &Store{provider: fp}
+
+	value := map[string]interface{}{}
+	if len(sessionInfo) > 0 {
+		value, _ = fp.config.UnSerializeFunc(sessionInfo)
+	}
+	store.Init("", value)
+	return store
+}
+
+// check the session id is a plain file name inside the save path,
+// at least 2 characters (the dir levels), no separator, no leading dot
+func checkSessionId(sessionId string) error {
+	if len(sessionId) < 2 || sessionId[0] == '.' || strings.ContainsAny(sessionId, "/\\:\x00") {
+		return ErrInvalidSessionId
+	}
+	return nil
+}
+
+// the session id can be used as a session file name
+func (fp *Provider) ValidSessionId(sessionId string) bool {
+	return checkSessionId(sessionId) == nil
+}
+
 // get session filePath, filename, fullFilename
+// the session id must pass checkSessionId
 func (fp *Provider) getSessionFile(sessionId string) (string, string, string) {
 	filePath := path.Join(fp.config.SavePath, string(sessionId[0]), string(sessionId[1]))
 	filename := sessionId + fp.config.Suffix
@@ -206,11 +468,97 @@ func (fp *Provider) getSessionFile(sessionId string) (string, string, string) {
 	return filePath, filename, fullFilename
 }
 
-// remove session file
-func (fp *Provider) removeSessionFile(sessionId string) {
+// get the session info and data of the session file, the provider lock must be held
+func (fp *Provider) peek(sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	_, _, fullFileName := fp.getSessionFile(sessionId)
+	fileInfo, err := os.Stat(fullFileName)
+	if os.IsNotExist(err) {
+		return fasthttpsession.SessionInfo{}, nil, fasthttpsession.ErrSessionNotFound
+	}
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	sessionInfo, err := fp.file.getContent(fullFileName)
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+
+	data := map[string]interface{}{}
+	if len(sessionInfo) > 0 {
+		data, err = fp.config.UnSerializeFunc(sessionInfo)
+		if err != nil {
+			return fasthttpsession.SessionInfo{}, nil, err
+		}
+	}
+	store := &Store{provider: fp}
+	store.Init(sessionId, data)
+
+	info := store.Info()
+	info.LastActiveAt = fileInfo.ModTime()
+	info.Size = len(sessionInfo)
+	return info, store.GetAll(), nil
+}
+
+// session ids of userID, the provider lock must be held
+func (fp *Provider) userSessions(userID string) ([]string, error) {
+	userDir := fp.getUserIndexDir(userID)
+	files, err := ioutil.ReadDir(userDir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIds := make([]string, 0, len(files))
+	for _, fi := range files {
+		sessionId := fi.Name()
+		_, _, fullFileName := fp.getSessionFile(sessionId)
+		sessionInfo, err := fp.file.getContent(fullFileName)
+		if err != nil || fp.unSerializeMeta(sessionInfo).UserID() != userID {
+			os.Remove(filepath.Join(userDir, sessionId))
+			continue
+		}
+		sessionIds = append(sessionIds, sessionId)
+	}
+	if len(sessionIds) == 0 {
+		os.Remove(userDir)
+	}
+	return sessionIds, nil
+}
+
+// add sessionId to the user id index, the provider lock must be held
+func (fp *Provider) indexUser(userID string, sessionId string) error {
+	if userID == "" {
+		return nil
+	}
+	userDir := fp.getUserIndexDir(userID)
+	err := os.MkdirAll(userDir, 0777)
+	if err != nil {
+		return err
+	}
+	return fp.file.createFile(filepath.Join(userDir, sessionId))
+}
+
+// get the user id index dir, the user id is hashed to a file name
+func (fp *Provider) getUserIndexDir(userID string) string {
+	return filepath.Join(fp.config.SavePath, userDirName, fmt.Sprintf("%x", sha1.Sum([]byte(userID))))
+}
+
+// get session lock filename
+func (fp *Provider) getSessionLockFile(sessionId string) string {
+	return filepath.Join(fp.config.SavePath, lockDirName, sessionId+".lock")
+}
+
+// remove session file, the empty dirs are removed on a best effort basis.
+// the lock file is kept, a request may wait on it, GC removes it
+func (fp *Provider) removeSessionFile(sessionId string) error {
 
 	filePath, _, fullFileName := fp.getSessionFile(sessionId)
-	os.Remove(fullFileName)
+	err := os.Remove(fullFileName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
 
 	// remove empty dir
 	s, _ := ioutil.ReadDir(filePath)
@@ -222,9 +570,25 @@ func (fp *Provider) removeSessionFile(sessionId string) {
 	if len(s) == 0 {
 		os.RemoveAll(filePath1)
 	}
+	return nil
+}
+
+// read the session file in a span of the tracer carried by ctx
+func (fp *Provider) readFile(ctx context.Context, filename string) ([]byte, error) {
+	_, span := fasthttpsession.StartSpan(ctx, "file read",
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBSystem, "file"))
+	data, err := fp.file.getContent(filename)
+	span.SetAttributes(fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, len(data)))
+	fasthttpsession.EndSpan(span, err)
+	return data, err
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, fileProvider)
+// write the session file in a span of the tracer carried by ctx
+func (fp *Provider) writeFile(ctx context.Context, filename string, data []byte) error {
+	_, span := fasthttpsession.StartSpan(ctx, "file write",
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBSystem, "file"),
+		fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, len(data)))
+	err := ioutil.WriteFile(filename, data, 0777)
+	fasthttpsession.EndSpan(span, err)
+	return err
 }
@@ -0,0 +1,160 @@
+package file
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+// new file provider of lifeTime(s) on a new save path
+func newTestProvider(t *testing.T, lifeTime int64) *Provider {
+	t.Helper()
+	provider := NewProvider()
+	if err := provider.Init(lifeTime, &Config{SavePath: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+// read the session, set data and save it
+func saveTestSession(t *testing.T, provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	t.Helper()
+	sessionStore, err := provider.ReadStore(sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range data {
+		sessionStore.Set(key, value)
+	}
+	if err := sessionStore.(*Store).SaveContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sessionStore.(*Store)
+}
+
+func TestStartInvalidSessionId(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionId string
+		replaced  bool
+	}{
+		{"valid session id", "valid-session-id", false},
+		{"one character", "a", true},
+		{"leading dot", ".hidden", true},
+		{"path separator", "a/../b", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fasthttpsession.NewDefaultConfig()
+			config.Logger = fasthttpsession.NewNopLogger()
+			session := fasthttpsession.NewSession(config)
+			if err := session.SetProvider(NewProvider(), &Config{SavePath: t.TempDir()}); err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close(context.Background())
+
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Init(&fasthttp.Request{}, nil, nil)
+			ctx.Request.Header.SetCookie(config.CookieName, test.sessionId)
+			sessionStore, err := session.Start(ctx)
+			if err != nil {
+				t.Fatalf("Start error: %v", err)
+			}
+			if replaced := sessionStore.GetSessionId() != test.sessionId; replaced != test.replaced {
+				t.Fatalf("session id = %q, want replaced %v", sessionStore.GetSessionId(), test.replaced)
+			}
+			if err := session.Save(ctx, sessionStore); err != nil {
+				t.Fatalf("Save error: %v", err)
+			}
+			if _, err := session.Regenerate(ctx); err != nil {
+				t.Fatalf("Regenerate error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGCSuffix(t *testing.T) {
+	tests := []struct {
+		name      string
+		suffix    string
+		sessionId string
+	}{
+		{"no suffix", "", "session1"},
+		{"suffix", ".session", "session1"},
+		{"id ending with suffix characters", ".session", "expires"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := NewProvider()
+			if err := provider.Init(60, &Config{SavePath: t.TempDir(), Suffix: test.suffix}); err != nil {
+				t.Fatal(err)
+			}
+			var expired []string
+			provider.SetExpireFunc(func(sessionId string) {
+				expired = append(expired, sessionId)
+			})
+			saveTestSession(t, provider, test.sessionId, map[string]interface{}{"a": "a"})
+			_, _, fullFileName := provider.getSessionFile(test.sessionId)
+			past := time.Now().Add(-2 * time.Minute)
+			if err := os.Chtimes(fullFileName, past, past); err != nil {
+				t.Fatal(err)
+			}
+
+			provider.GC()
+			if len(expired) != 1 || expired[0] != test.sessionId {
+				t.Fatalf("expired sessions = %v, want [%s]", expired, test.sessionId)
+			}
+			if provider.file.pathIsExists(fullFileName) {
+				t.Fatal("expired session file not removed")
+			}
+		})
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	provider := newTestProvider(t, 60)
+	sessionStore, err := provider.ReadStore("old-session-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStore := sessionStore.(*Store)
+	oldStore.Set("a", "a")
+	oldStore.SetUserID("user1")
+	oldStore.SetFingerprint("fingerprint1")
+	if err := oldStore.SaveContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sessionStore, err = provider.ReadStore("old-session-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := sessionStore.(*Store).CreatedAt()
+
+	sessionStore, err = provider.Regenerate("old-session-id", "new-session-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := sessionStore.(*Store)
+	if store.GetSessionId() != "new-session-id" {
+		t.Fatalf("session id = %q, want new-session-id", store.GetSessionId())
+	}
+	if value := store.Get("a"); value != "a" {
+		t.Fatalf("data a = %v, want a", value)
+	}
+	if store.UserID() != "user1" {
+		t.Fatalf("user id = %q, want user1", store.UserID())
+	}
+	if store.Fingerprint() != "fingerprint1" {
+		t.Fatalf("fingerprint = %q, want fingerprint1", store.Fingerprint())
+	}
+	if !store.CreatedAt().Equal(created) {
+		t.Fatalf("created = %v, want %v", store.CreatedAt(), created)
+	}
+	if ok, err := provider.Exists(context.Background(), "old-session-id"); err != nil || ok {
+		t.Fatalf("old session exists = %v, %v, want false", ok, err)
+	}
+}
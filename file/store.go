@@ -1,7 +1,7 @@
 package file
 
 import (
-	"io/ioutil"
+	"context"
 	"os"
 	"time"
 
@@ -11,23 +11,55 @@ import (
 
 type Store struct {
 	fasthttpsession.Store
+	provider *Provider
 }
 
 // save store
 func (fs *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return fs.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+// fasthttpsession.ErrConflict is returned if the session version moved since it was read
+func (fs *Store) SaveContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	fileProvider.lock.Lock()
-	defer fileProvider.lock.Unlock()
+	fs.provider.lock.Lock()
+	defer fs.provider.lock.Unlock()
 
 	sessionId := fs.GetSessionId()
 
-	_, _, fullFileName := fileProvider.getSessionFile(sessionId)
+	_, _, fullFileName := fs.provider.getSessionFile(sessionId)
 
-	if fileProvider.file.pathIsExists(fullFileName) {
-		sessionMap := fs.GetAll()
-		sessionInfo, _ := fileProvider.config.SerializeFunc(sessionMap)
-		ioutil.WriteFile(fullFileName, sessionInfo, 0777)
-		os.Chtimes(fullFileName, time.Now(), time.Now())
+	if fs.provider.file.pathIsExists(fullFileName) {
+		// an unchanged store only updates the file time
+		if fs.IsDirty() {
+			oldSessionInfo, err := fs.provider.readFile(ctx, fullFileName)
+			if err != nil {
+				return err
+			}
+			if fs.provider.unSerializeMeta(oldSessionInfo).Version() != fs.Version() {
+				return fasthttpsession.ErrConflict
+			}
+			sessionMap := fs.Export()
+			sessionInfo, err := fs.provider.config.SerializeFunc(sessionMap)
+			if err != nil {
+				return err
+			}
+			err = fs.provider.writeFile(ctx, fullFileName, sessionInfo)
+			if err != nil {
+				return err
+			}
+			fs.MarkSaved()
+			fs.SetSize(len(sessionInfo))
+		}
+		err := os.Chtimes(fullFileName, time.Now(), time.Now())
+		if err != nil {
+			return err
+		}
+		return fs.provider.indexUser(fs.UserID(), sessionId)
 	}
 	return nil
 }
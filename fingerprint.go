@@ -0,0 +1,114 @@
+package fasthttpsession
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// FingerprintPolicy is applied by Start when the client fingerprint does not match
+// the fingerprint bound to the session
+type FingerprintPolicy int
+
+const (
+	// destroy the session and start a new one, the default
+	FingerprintDestroy FingerprintPolicy = iota
+
+	// keep the session for the client bound to it, start a new empty session
+	// for the mismatched client, e.g. a second device of a shared cookie
+	FingerprintRegenerate
+
+	// keep the session, Session.FingerprintMismatch reports the mismatch to the handler
+	FingerprintFlag
+)
+
+// FingerprintIP returns a fingerprint func of the client IP subnet,
+// e.g. FingerprintIP(24, 64) tolerates address changes inside a /24 or a /64.
+// behind a proxy, the client IP must be taken from the proxy header by a custom func.
+func FingerprintIP(ipv4Bits int, ipv6Bits int) func(ctx *fasthttp.RequestCtx) string {
+	return func(ctx *fasthttp.RequestCtx) string {
+		ip := ctx.RemoteIP()
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(net.CIDRMask(ipv4Bits, 8*net.IPv4len)).String()
+		}
+		return ip.Mask(net.CIDRMask(ipv6Bits, 8*net.IPv6len)).String()
+	}
+}
+
+// FingerprintUserAgent is a fingerprint func of the client User-Agent
+func FingerprintUserAgent(ctx *fasthttp.RequestCtx) string {
+	return string(ctx.Request.Header.UserAgent())
+}
+
+// FingerprintAll returns a fingerprint func combining all the fingerprint funcs,
+// e.g. FingerprintAll(FingerprintIP(24, 64), FingerprintUserAgent)
+func FingerprintAll(fingerprintFuncs ...func(ctx *fasthttp.RequestCtx) string) func(ctx *fasthttp.RequestCtx) string {
+	return func(ctx *fasthttp.RequestCtx) string {
+		fingerprints := make([]string, len(fingerprintFuncs))
+		for i, fingerprintFunc := range fingerprintFuncs {
+			fingerprints[i] = fingerprintFunc(ctx)
+		}
+		return strings.Join(fingerprints, "\n")
+	}
+}
+
+// the session fingerprint did not match the client in this request, with FingerprintFlag
+func (s *Session) FingerprintMismatch(ctx *fasthttp.RequestCtx) bool {
+	mismatch, _ := ctx.UserValue(s.fingerprintUserValueKey).(bool)
+	return mismatch
+}
+
+// check the session is used by the client bound to it, see Config.FingerprintFunc
+// a session without fingerprint is bound to the client, else the policy is applied on a mismatch
+func (s *Session) checkFingerprint(ctx *fasthttp.RequestCtx, c context.Context, sessionStore SessionStore) (SessionStore, error) {
+	fingerprint := s.fingerprint(ctx)
+	storeFingerprint := sessionStore.Fingerprint()
+	if storeFingerprint == "" {
+		sessionStore.SetFingerprint(fingerprint)
+		return sessionStore, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(storeFingerprint), []byte(fingerprint)) == 1 {
+		return sessionStore, nil
+	}
+
+	if s.config.OnFingerprintMismatch != nil {
+		s.config.OnFingerprintMismatch(ctx, sessionStore)
+	}
+
+	if s.config.FingerprintPolicy == FingerprintFlag {
+		ctx.SetUserValue(s.fingerprintUserValueKey, true)
+		return sessionStore, nil
+	}
+
+	sessionId := s.config.SessionIdGenerator()
+	if sessionId == "" {
+		return sessionStore, errors.New("session generator sessionId is empty")
+	}
+	// the session data never moves to the mismatched client
+	if s.config.FingerprintPolicy != FingerprintRegenerate {
+		oldSessionId := sessionStore.GetSessionId()
+		if err := s.destroyStore(c, oldSessionId); err != nil {
+			s.logError("session destroy error", "fingerprint", oldSessionId, err)
+		}
+		s.onDestroy(ctx, oldSessionId)
+	}
+	sessionStore, err := s.readStore(c, sessionId)
+	if err != nil {
+		return sessionStore, err
+	}
+	sessionStore.SetFingerprint(fingerprint)
+	s.onCreate(ctx, sessionStore)
+	return sessionStore, nil
+}
+
+// hash of the client fingerprint, the session does not keep the client data
+func (s *Session) fingerprint(ctx *fasthttp.RequestCtx) string {
+	sum := sha256.Sum256([]byte(s.config.FingerprintFunc(ctx)))
+	return hex.EncodeToString(sum[:])
+}
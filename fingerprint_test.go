@@ -0,0 +1,85 @@
+package fasthttpsession_test
+
+import (
+	"testing"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/valyala/fasthttp"
+)
+
+func TestFingerprintPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       fasthttpsession.FingerprintPolicy
+		sameSession  bool
+		victimKeeps  bool
+		flagMismatch bool
+	}{
+		{"destroy", fasthttpsession.FingerprintDestroy, false, false, false},
+		{"regenerate", fasthttpsession.FingerprintRegenerate, false, true, false},
+		{"flag", fasthttpsession.FingerprintFlag, true, true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fasthttpsession.NewDefaultConfig()
+			config.FingerprintFunc = fasthttpsession.FingerprintUserAgent
+			config.FingerprintPolicy = test.policy
+			mismatches := 0
+			config.OnFingerprintMismatch = func(ctx *fasthttp.RequestCtx, sessionStore fasthttpsession.SessionStore) {
+				mismatches++
+			}
+			session := newTestSession(t, config)
+
+			// the victim logs in
+			victim := newTestCtx("", "")
+			victim.Request.Header.SetUserAgent("victim")
+			victimStore, err := session.Start(victim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			victimStore.Set("user", "victim")
+			if err := session.Save(victim, victimStore); err != nil {
+				t.Fatal(err)
+			}
+			sessionId := victimStore.GetSessionId()
+
+			// the stolen cookie is used by another client
+			thief := newTestCtx(config.CookieName, sessionId)
+			thief.Request.Header.SetUserAgent("thief")
+			thiefStore, err := session.Start(thief)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mismatches != 1 {
+				t.Fatalf("OnFingerprintMismatch called %d times, want 1", mismatches)
+			}
+			if got := thiefStore.GetSessionId() == sessionId; got != test.sameSession {
+				t.Fatalf("mismatched client got the session: %v, want %v", got, test.sameSession)
+			}
+			if !test.sameSession && thiefStore.Get("user") != nil {
+				t.Fatalf("mismatched client got the session data %v", thiefStore.GetAll())
+			}
+			if got := session.FingerprintMismatch(thief); got != test.flagMismatch {
+				t.Fatalf("FingerprintMismatch = %v, want %v", got, test.flagMismatch)
+			}
+			if err := session.Save(thief, thiefStore); err != nil {
+				t.Fatal(err)
+			}
+
+			// the victim comes back
+			victim = newTestCtx(config.CookieName, sessionId)
+			victim.Request.Header.SetUserAgent("victim")
+			victimStore, err = session.Start(victim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept := victimStore.GetSessionId() == sessionId && victimStore.Get("user") == "victim"
+			if kept != test.victimKeeps {
+				t.Fatalf("victim kept the session: %v, want %v", kept, test.victimKeeps)
+			}
+			if mismatches != 1 {
+				t.Fatalf("OnFingerprintMismatch called %d times for the victim", mismatches-1)
+			}
+		})
+	}
+}
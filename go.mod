@@ -1,3 +1,23 @@
 module github.com/brunohass/fasthttpsession
 
-go 1.17
+go 1.25.0
+
+require (
+	github.com/go-sql-driver/mysql v1.10.1
+	github.com/lib/pq v1.12.3
+	github.com/mattn/go-sqlite3 v1.14.52
+	github.com/orcaman/concurrent-map v1.0.0
+	github.com/segmentio/ksuid v1.0.4
+	github.com/valyala/fasthttp v1.74.0
+)
+
+require (
+	filippo.io/edwards25519 v1.2.0 // indirect
+	github.com/gomodule/redigo v1.9.3
+	github.com/klauspost/compress v1.20.0 // indirect
+	github.com/linuxpham/gomemcache v0.0.0-20230905024940-24af94b03874
+	github.com/molecule-man/go-brrr v1.0.1 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+)
+
+replace github.com/linuxpham/gomemcache => github.com/bradfitz/gomemcache v0.0.0-20230905024940-24af94b03874
@@ -0,0 +1,75 @@
+package fasthttpsession
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+// Hooks are the session lifecycle callbacks, e.g. to audit logins or to clean up
+// the resources of a session. A nil hook is skipped, ctx is nil outside a request.
+type Hooks struct {
+	// a new session is started, the session id was generated by Start or Regenerate
+	OnCreate func(ctx *fasthttp.RequestCtx, sessionStore SessionStore)
+
+	// the session of the session id sent by the client is started
+	OnLoad func(ctx *fasthttp.RequestCtx, sessionStore SessionStore)
+
+	// the session is saved
+	OnSave func(ctx *fasthttp.RequestCtx, sessionStore SessionStore)
+
+	// the session id is regenerated, the session data is kept
+	OnRegenerate func(ctx *fasthttp.RequestCtx, oldSessionId string, sessionId string)
+
+	// the session is destroyed
+	OnDestroy func(ctx *fasthttp.RequestCtx, sessionId string)
+
+	// the session expired, found by Start for the absolute lifetime or by the provider gc.
+	// redis, memcache and cookie sessions expire without a gc, only their absolute lifetime is reported.
+	OnExpire func(sessionId string)
+}
+
+// ExpireNotifyProvider is a Provider whose GC reports the sessions it expired,
+// the expire func is only set with Hooks.OnExpire or Config.Metrics
+type ExpireNotifyProvider interface {
+	Provider
+	SetExpireFunc(expireFunc func(sessionId string))
+}
+
+func (s *Session) onCreate(ctx *fasthttp.RequestCtx, sessionStore SessionStore) {
+	s.incSessions(MetricSessionCreated)
+	if s.config.Hooks.OnCreate != nil {
+		s.config.Hooks.OnCreate(ctx, sessionStore)
+	}
+}
+
+func (s *Session) onLoad(ctx *fasthttp.RequestCtx, sessionStore SessionStore) {
+	if s.config.Hooks.OnLoad != nil {
+		s.config.Hooks.OnLoad(ctx, sessionStore)
+	}
+}
+
+func (s *Session) onSave(ctx *fasthttp.RequestCtx, sessionStore SessionStore) {
+	if s.config.Hooks.OnSave != nil {
+		s.config.Hooks.OnSave(ctx, sessionStore)
+	}
+}
+
+func (s *Session) onRegenerate(ctx *fasthttp.RequestCtx, oldSessionId string, sessionId string) {
+	s.incSessions(MetricSessionRegenerated)
+	if s.config.Hooks.OnRegenerate != nil {
+		s.config.Hooks.OnRegenerate(ctx, oldSessionId, sessionId)
+	}
+}
+
+func (s *Session) onDestroy(ctx *fasthttp.RequestCtx, sessionId string) {
+	s.incSessions(MetricSessionDestroyed)
+	if s.config.Hooks.OnDestroy != nil {
+		s.config.Hooks.OnDestroy(ctx, sessionId)
+	}
+}
+
+func (s *Session) onExpire(sessionId string) {
+	s.incSessions(MetricSessionExpired)
+	if s.config.Hooks.OnExpire != nil {
+		s.config.Hooks.OnExpire(sessionId)
+	}
+}
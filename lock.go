@@ -0,0 +1,143 @@
+package fasthttpsession
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var ErrLockNotSupported = errors.New("session lock error, provider does not implement LockProvider")
+
+const (
+	lockRetryMinDelay = 5 * time.Millisecond
+	lockRetryMaxDelay = 100 * time.Millisecond
+)
+
+// session lock held by a request,
+// fasthttp closes it after the request if it was not released before
+type requestLock struct {
+	unlock func() error
+	once   sync.Once
+}
+
+func (l *requestLock) Close() (err error) {
+	l.once.Do(func() {
+		err = l.unlock()
+	})
+	return err
+}
+
+// StartLocked starts the session like Start, holding the session lock until
+// Unlock is called or the request ends. Concurrent requests of the same session
+// wait for the lock, at most Config.LockTimeout, so their changes are not lost.
+// The middleware releases the lock after saving the session.
+func (s *Session) StartLocked(ctx *fasthttp.RequestCtx) (SessionStore, error) {
+	return s.start(ctx, true)
+}
+
+// Unlock releases the session lock held by the request, see StartLocked
+func (s *Session) Unlock(ctx *fasthttp.RequestCtx) error {
+	lock, ok := ctx.UserValue(s.lockUserValueKey).(*requestLock)
+	if !ok {
+		return nil
+	}
+	ctx.RemoveUserValue(s.lockUserValueKey)
+	return lock.Close()
+}
+
+// lock the session for the request, nothing to do if the request holds it already
+func (s *Session) lock(ctx *fasthttp.RequestCtx, sessionId string) error {
+	if ctx.UserValue(s.lockUserValueKey) != nil {
+		return nil
+	}
+	provider, ok := s.provider.(LockProvider)
+	if !ok {
+		return ErrLockNotSupported
+	}
+
+	c, cancel := context.WithTimeout(ContextWithRequestCtx(s.parentContext(ctx), ctx), s.config.LockTimeout)
+	defer cancel()
+
+	c, span := s.startSpan(c, "fasthttpsession.Lock")
+	unlock, err := provider.Lock(c, sessionId)
+	EndSpan(span, err)
+	if err != nil {
+		return err
+	}
+	ctx.SetUserValue(s.lockUserValueKey, &requestLock{unlock: unlock})
+	return nil
+}
+
+// WaitLock calls tryLock until it acquires the lock, returns an error or ctx is done.
+// It is used by the providers whose backend can only try a lock.
+func WaitLock(ctx context.Context, tryLock func() (bool, error)) error {
+	delay := lockRetryMinDelay
+	for {
+		ok, err := tryLock()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if delay < lockRetryMaxDelay {
+			delay *= 2
+		}
+	}
+}
+
+// SessionLocker locks sessions inside the process,
+// for the providers whose backend is not shared with other processes.
+type SessionLocker struct {
+	lock  sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// return new SessionLocker
+func NewSessionLocker() *SessionLocker {
+	return &SessionLocker{
+		locks: make(map[string]chan struct{}),
+	}
+}
+
+// Lock blocks until the lock of sessionId is acquired or ctx is done
+func (sl *SessionLocker) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	for {
+		sl.lock.Lock()
+		held, ok := sl.locks[sessionId]
+		if !ok {
+			released := make(chan struct{})
+			sl.locks[sessionId] = released
+			sl.lock.Unlock()
+
+			var once sync.Once
+			return func() error {
+				once.Do(func() {
+					sl.lock.Lock()
+					delete(sl.locks, sessionId)
+					sl.lock.Unlock()
+					close(released)
+				})
+				return nil
+			}, nil
+		}
+		sl.lock.Unlock()
+
+		select {
+		case <-held:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package fasthttpsession
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is a structured logger, keysAndValues are alternating keys and values,
+// e.g. "sessionId", id, "op", "save", "error", err. *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// LoggerProvider is a Provider which logs the failures it can not return,
+// e.g. in GC or in a best effort index cleanup
+type LoggerProvider interface {
+	Provider
+	SetLogger(logger Logger)
+}
+
+// return a Logger writing the warnings and errors with the standard logger,
+// nil means log.Default()
+func NewStdLogger(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &stdLogger{logger: logger}
+}
+
+// return a Logger discarding everything
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func (sl *stdLogger) Debug(msg string, keysAndValues ...interface{}) {}
+
+func (sl *stdLogger) Info(msg string, keysAndValues ...interface{}) {}
+
+func (sl *stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	sl.print("WARN", msg, keysAndValues)
+}
+
+func (sl *stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	sl.print("ERROR", msg, keysAndValues)
+}
+
+// level msg key=value ...
+func (sl *stdLogger) print(level string, msg string, keysAndValues []interface{}) {
+	var line strings.Builder
+	line.WriteString(level)
+	line.WriteString(" ")
+	line.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "!MISSING"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fmt.Fprintf(&line, " %v=%q", keysAndValues[i], fmt.Sprint(value))
+	}
+	sl.logger.Print(line.String())
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+
+func (nopLogger) Info(msg string, keysAndValues ...interface{}) {}
+
+func (nopLogger) Warn(msg string, keysAndValues ...interface{}) {}
+
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+// the config logger, nop if it is not set
+func (c *Config) logger() Logger {
+	if c.Logger == nil {
+		return nopLogger{}
+	}
+	return c.Logger
+}
+
+// log a failure which can not be returned, with the provider, operation and session id fields
+func (s *Session) logError(msg string, op string, sessionId string, err error) {
+	s.config.logger().Error(msg, "provider", s.providerName, "op", op, "sessionId", sessionId, "error", err)
+}
@@ -2,6 +2,8 @@ package memcache
 
 // session memcache config
 
+const defaultLockExpire = int64(30)
+
 type Config struct {
 
 	// memcache server list
@@ -18,6 +20,10 @@ type Config struct {
 	// sessionId as memcache key prefix
 	KeyPrefix string
 
+	// session lock expire, the lock is released if its owner never unlocks it
+	// (s) default 30
+	LockExpire int64
+
 	// session value serialize func
 	SerializeFunc func(data map[string]interface{}) ([]byte, error)
 
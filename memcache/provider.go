@@ -1,8 +1,13 @@
 package memcache
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"errors"
+	"io"
 	"reflect"
+	"time"
 
 	"github.com/brunohass/fasthttpsession"
 	"github.com/linuxpham/gomemcache/memcache"
@@ -12,16 +17,18 @@ import (
 
 const ProviderName = "memcache"
 
-var (
-	provider = NewProvider()
-	encrypt  = fasthttpsession.NewEncrypt()
-)
+// memcache reads an expiration over 30 days as an absolute unix time
+const maxRelativeExpiration = 30 * 24 * 3600
+
+var encrypt = fasthttpsession.NewEncrypt()
 
 type Provider struct {
-	config         *Config
-	values         *fasthttpsession.CCMap
-	memCacheClient *memcache.Client
-	maxLifeTime    int64
+	config           *Config
+	values           *fasthttpsession.CCMap
+	memCacheClient   *memcache.Client
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	logger           fasthttpsession.Logger
 }
 
 // new memcache provider
@@ -30,6 +37,7 @@ func NewProvider() *Provider {
 		config:         &Config{},
 		values:         fasthttpsession.NewDefaultCCMap(),
 		memCacheClient: &memcache.Client{},
+		logger:         fasthttpsession.NewNopLogger(),
 	}
 }
 
@@ -49,6 +57,9 @@ func (mcp *Provider) Init(lifeTime int64, memCacheConfig fasthttpsession.Provide
 	if mcp.config.MaxIdle <= 0 {
 		return errors.New("session memcache provider init error, config MaxIdle must be more than 0")
 	}
+	if mcp.config.LockExpire <= 0 {
+		mcp.config.LockExpire = defaultLockExpire
+	}
 	// init config serialize func
 	if mcp.config.SerializeFunc == nil {
 		mcp.config.SerializeFunc = encrypt.GobEncode
@@ -63,6 +74,16 @@ func (mcp *Provider) Init(lifeTime int64, memCacheConfig fasthttpsession.Provide
 	return nil
 }
 
+// set the absolute session lifetime(s), 0 means no limit
+func (mcp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	mcp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the logger of the failures which can not be returned
+func (mcp *Provider) SetLogger(logger fasthttpsession.Logger) {
+	mcp.logger = logger
+}
+
 // not need gc
 func (mcp *Provider) NeedGC() bool {
 	return false
@@ -73,19 +94,35 @@ func (mcp *Provider) GC() {}
 
 // read session store by session id
 func (mcp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return mcp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+// the memcache client has no context support, ctx is checked before the round-trip
+func (mcp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	memClient := mcp.getMemCacheClient()
 
+	span := mcp.startSpan(ctx, "get")
 	item, err := memClient.Get(mcp.getMemCacheSessionKey(sessionId))
+	if err == nil {
+		span.SetAttributes(fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, len(item.Value)))
+	}
+	endSpan(span, err)
 	if err != nil {
 		if err == memcache.ErrCacheMiss {
-			return NewMemCacheStore(sessionId), nil
+			return NewMemCacheStore(mcp, sessionId), nil
 		} else {
 			return nil, err
 		}
 	}
 	if len(item.Value) == 0 {
-		return NewMemCacheStore(sessionId), nil
+		store := NewMemCacheStore(mcp, sessionId)
+		store.item = item
+		return store, nil
 	}
 
 	data, err := mcp.config.UnSerializeFunc(item.Value)
@@ -93,46 +130,139 @@ func (mcp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore,
 		return nil, err
 	}
 
-	return NewMemCacheStoreData(sessionId, data), nil
+	store := NewMemCacheStoreData(mcp, sessionId, data)
+	store.item = item
+	store.SetSize(len(item.Value))
+	return store, nil
 }
 
 // regenerate session
 func (mcp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return mcp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+func (mcp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	memClient := mcp.getMemCacheClient()
 
 	item, err := memClient.Get(mcp.getMemCacheSessionKey(oldSessionId))
-	if err != nil || len(item.Value) == 0 {
+	if err != nil && err != memcache.ErrCacheMiss {
+		return nil, err
+	}
+	if err == memcache.ErrCacheMiss || len(item.Value) == 0 {
 		// false, old sessionId not exists
 		err := memClient.Set(&memcache.Item{
 			Key:        mcp.getMemCacheSessionKey(sessionId),
 			Value:      []byte(""),
-			Expiration: int32(mcp.maxLifeTime),
+			Expiration: expiration(mcp.maxLifeTime),
 		})
 		if err != nil {
 			return nil, err
 		}
-		return NewMemCacheStore(sessionId), nil
+		return NewMemCacheStore(mcp, sessionId), nil
 	}
 	// true, old sessionId exists, delete old sessionId
 	err = memClient.Delete(mcp.getMemCacheSessionKey(oldSessionId))
 	if err != nil {
 		return nil, err
 	}
+	// keep the TTL of the session
+	data, err := mcp.config.UnSerializeFunc(item.Value)
+	if err != nil {
+		return nil, err
+	}
+	store := NewMemCacheStoreData(mcp, sessionId, data)
 	item.Key = mcp.getMemCacheSessionKey(sessionId)
-	item.Expiration = int32(mcp.maxLifeTime)
+	item.Expiration = expiration(store.Lifetime(mcp.maxLifeTime, mcp.absoluteLifeTime))
 	err = memClient.Set(item)
 	if err != nil {
 		return nil, err
 	}
 
-	return mcp.ReadStore(sessionId)
+	return mcp.ReadStoreContext(ctx, sessionId)
 }
 
 // destroy session by sessionId
 func (mcp *Provider) Destroy(sessionId string) error {
+	return mcp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (mcp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	memClient := mcp.getMemCacheClient()
+	span := mcp.startSpan(ctx, "delete")
+	err := memClient.Delete(mcp.getMemCacheSessionKey(sessionId))
+	if err == memcache.ErrCacheMiss {
+		// already expired or evicted
+		err = nil
+	}
+	endSpan(span, err)
+	return err
+}
+
+// session exists by sessionId
+func (mcp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	memClient := mcp.getMemCacheClient()
-	return memClient.Delete(mcp.getMemCacheSessionKey(sessionId))
+	_, err := memClient.Get(mcp.getMemCacheSessionKey(sessionId))
+	if err == memcache.ErrCacheMiss {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lock session by sessionId, Add a random token with the LockExpire expiry
+func (mcp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, err
+	}
+	memClient := mcp.getMemCacheClient()
+	lockKey := mcp.getMemCacheLockKey(sessionId)
+
+	err = fasthttpsession.WaitLock(ctx, func() (bool, error) {
+		err := memClient.Add(&memcache.Item{
+			Key:        lockKey,
+			Value:      token,
+			Expiration: expiration(mcp.config.LockExpire),
+		})
+		if err == memcache.ErrNotStored {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		// the lock may have expired and been taken by another request
+		item, err := memClient.Get(lockKey)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(item.Value, token) {
+			return nil
+		}
+		err = memClient.Delete(lockKey)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		return err
+	}, nil
 }
 
 // session values count
@@ -140,11 +270,25 @@ func (mcp *Provider) Count() int {
 	return 0
 }
 
+// close the memcache client idle connections
+// older memcache clients have no Close, there is nothing to release then
+func (mcp *Provider) Close() error {
+	if closer, ok := interface{}(mcp.memCacheClient).(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 // get memcache session key, prefix:sessionId
 func (mcp *Provider) getMemCacheSessionKey(sessionId string) string {
 	return mcp.config.KeyPrefix + ":" + sessionId
 }
 
+// get memcache session lock key, prefix_lock:sessionId
+func (mcp *Provider) getMemCacheLockKey(sessionId string) string {
+	return mcp.config.KeyPrefix + "_lock:" + sessionId
+}
+
 func (mcp *Provider) getMemCacheClient() *memcache.Client {
 	if mcp.memCacheClient == nil {
 		mcp.memCacheClient = memcache.New(mcp.config.ServerList...)
@@ -152,7 +296,27 @@ func (mcp *Provider) getMemCacheClient() *memcache.Client {
 	return mcp.memCacheClient
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, provider)
+// memcache item expiration of lifeTime(s) from now,
+// a lifetime over 30 days is converted to a unix time
+func expiration(lifeTime int64) int32 {
+	if lifeTime > maxRelativeExpiration {
+		return int32(time.Now().Unix() + lifeTime)
+	}
+	return int32(lifeTime)
+}
+
+// start a span of the memcache command with the tracer carried by ctx
+func (mcp *Provider) startSpan(ctx context.Context, commandName string) fasthttpsession.Span {
+	_, span := fasthttpsession.StartSpan(ctx, "memcache "+commandName,
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBSystem, "memcached"),
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBOperation, commandName))
+	return span
+}
+
+// end the span of a memcache command, a cache miss is not an error
+func endSpan(span fasthttpsession.Span, err error) {
+	if err == memcache.ErrCacheMiss {
+		err = nil
+	}
+	fasthttpsession.EndSpan(span, err)
 }
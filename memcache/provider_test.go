@@ -0,0 +1,154 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+)
+
+func TestExpiration(t *testing.T) {
+	tests := []struct {
+		name     string
+		lifeTime int64
+		absolute bool
+	}{
+		{"no expiration", 0, false},
+		{"minutes", 1200, false},
+		{"30 days", maxRelativeExpiration, false},
+		{"over 30 days", maxRelativeExpiration + 1, true},
+		{"remember me", 90 * 24 * 3600, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Now().Unix()
+			got := int64(expiration(test.lifeTime))
+			if !test.absolute {
+				if got != test.lifeTime {
+					t.Fatalf("expiration(%d) = %d, want %d", test.lifeTime, got, test.lifeTime)
+				}
+				return
+			}
+			if got < now+test.lifeTime || got > time.Now().Unix()+test.lifeTime {
+				t.Fatalf("expiration(%d) = %d, want the unix time %d", test.lifeTime, got, now+test.lifeTime)
+			}
+		})
+	}
+}
+
+// new memcache provider on a fake server, replies maps a command name to its reply line,
+// commands returns the commands received
+func newTestProvider(t *testing.T, replies map[string]string) (provider *Provider, commands func() []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	var lock sync.Mutex
+	received := []string{}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.Fields(line)[0]
+			lock.Lock()
+			received = append(received, command)
+			lock.Unlock()
+			if command == "set" || command == "add" || command == "cas" {
+				// skip the data block
+				if _, err := reader.ReadString('\n'); err != nil {
+					return
+				}
+			}
+			conn.Write([]byte(replies[command] + "\r\n"))
+		}
+	}()
+
+	provider = NewProvider()
+	if err := provider.Init(60, &Config{ServerList: []string{listener.Addr().String()}, MaxIdle: 1}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestRegenerateDestroyErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		call         func(provider *Provider) error
+		replies      map[string]string
+		wantErr      bool
+		wantCommands []string
+	}{
+		{
+			name: "regenerate missing session",
+			call: func(provider *Provider) error {
+				_, err := provider.RegenerateContext(context.Background(), "old", "new")
+				return err
+			},
+			replies:      map[string]string{"gets": "END", "set": "STORED"},
+			wantCommands: []string{"gets", "set"},
+		},
+		{
+			name: "regenerate read error",
+			call: func(provider *Provider) error {
+				_, err := provider.RegenerateContext(context.Background(), "old", "new")
+				return err
+			},
+			replies:      map[string]string{"gets": "SERVER_ERROR out of memory"},
+			wantErr:      true,
+			wantCommands: []string{"gets"},
+		},
+		{
+			name: "destroy missing session",
+			call: func(provider *Provider) error {
+				return provider.DestroyContext(context.Background(), "s1")
+			},
+			replies:      map[string]string{"delete": "NOT_FOUND"},
+			wantCommands: []string{"delete"},
+		},
+		{
+			name: "destroy error",
+			call: func(provider *Provider) error {
+				return provider.DestroyContext(context.Background(), "s1")
+			},
+			replies:      map[string]string{"delete": "SERVER_ERROR out of memory"},
+			wantErr:      true,
+			wantCommands: []string{"delete"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider, commands := newTestProvider(t, test.replies)
+			err := test.call(provider)
+			if test.wantErr != (err != nil) {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if errors.Is(err, fasthttpsession.ErrSessionNotFound) {
+				t.Fatalf("error = %v, want the server error", err)
+			}
+			if got := commands(); strings.Join(got, " ") != strings.Join(test.wantCommands, " ") {
+				t.Fatalf("commands = %v, want %v", got, test.wantCommands)
+			}
+		})
+	}
+}
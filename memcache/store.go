@@ -1,6 +1,9 @@
 package memcache
 
 import (
+	"bytes"
+	"context"
+
 	"github.com/brunohass/fasthttpsession"
 	"github.com/linuxpham/gomemcache/memcache"
 	"github.com/valyala/fasthttp"
@@ -9,34 +12,90 @@ import (
 // session memCache store
 
 // new default memCache store
-func NewMemCacheStore(sessionId string) *Store {
-	memCacheStore := &Store{}
+func NewMemCacheStore(provider *Provider, sessionId string) *Store {
+	memCacheStore := &Store{provider: provider}
 	memCacheStore.Init(sessionId, make(map[string]interface{}))
 	return memCacheStore
 }
 
 // new memCache store data
-func NewMemCacheStoreData(sessionId string, data map[string]interface{}) *Store {
-	memCacheStore := &Store{}
+func NewMemCacheStoreData(provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	memCacheStore := &Store{provider: provider}
 	memCacheStore.Init(sessionId, data)
 	return memCacheStore
 }
 
 type Store struct {
 	fasthttpsession.Store
+	provider *Provider
+
+	// item read from memcache, its cas id guards the next save, nil for a new session
+	item *memcache.Item
 }
 
 // save store
 func (mcs *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return mcs.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+// an unchanged store only touches the item expiration
+// changed data is written by compare-and-swap, fasthttpsession.ErrConflict
+// is returned if the item changed since it was read
+func (mcs *Store) SaveContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	memClient := mcs.provider.getMemCacheClient()
+	key := mcs.provider.getMemCacheSessionKey(mcs.GetSessionId())
+	lifeTime := mcs.Lifetime(mcs.provider.maxLifeTime, mcs.provider.absoluteLifeTime)
+	if !mcs.IsDirty() {
+		span := mcs.provider.startSpan(ctx, "touch")
+		err := memClient.Touch(key, expiration(lifeTime))
+		endSpan(span, err)
+		if err != memcache.ErrCacheMiss {
+			return err
+		}
+		// item evicted, write it again
+		mcs.item = nil
+	}
 
-	value, err := provider.config.SerializeFunc(mcs.GetAll())
+	value, err := mcs.provider.config.SerializeFunc(mcs.Export())
 	if err != nil {
 		return err
 	}
 
-	return provider.memCacheClient.Set(&memcache.Item{
-		Key:        provider.getMemCacheSessionKey(mcs.GetSessionId()),
-		Value:      value,
-		Expiration: int32(provider.maxLifeTime),
-	})
+	span := mcs.provider.startSpan(ctx, "set")
+	span.SetAttributes(fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, len(value)))
+	if mcs.item == nil {
+		err = memClient.Add(&memcache.Item{
+			Key:        key,
+			Value:      value,
+			Expiration: expiration(lifeTime),
+		})
+	} else {
+		mcs.item.Value = value
+		mcs.item.Expiration = expiration(lifeTime)
+		err = memClient.CompareAndSwap(mcs.item)
+	}
+	endSpan(span, err)
+	if err == memcache.ErrNotStored || err == memcache.ErrCASConflict {
+		return fasthttpsession.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	mcs.MarkSaved()
+	mcs.SetSize(len(value))
+
+	// read the new cas id, unless the item was changed again already
+	item, err := memClient.Get(key)
+	if err != nil && err != memcache.ErrCacheMiss {
+		mcs.provider.logger.Warn("session cas id read error", "provider", ProviderName, "op", "save", "sessionId", mcs.GetSessionId(), "error", err)
+	}
+	if err == nil && bytes.Equal(item.Value, value) {
+		mcs.item = item
+	}
+	return nil
 }
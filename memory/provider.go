@@ -1,8 +1,11 @@
 package memory
 
 import (
+	"context"
 	"errors"
 	"reflect"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -13,9 +16,16 @@ import (
 const ProviderName = "memory"
 
 type Provider struct {
-	config      *Config
-	values      *fasthttpsession.CCMap
-	maxLifeTime int64
+	config           *Config
+	values           *fasthttpsession.CCMap
+	locker           *fasthttpsession.SessionLocker
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	expireFunc       func(sessionId string)
+
+	// user id index, userID => sessionIds
+	usersLock sync.Mutex
+	users     map[string]map[string]struct{}
 }
 
 // new memory provider
@@ -23,7 +33,9 @@ func NewProvider() *Provider {
 	return &Provider{
 		config:      &Config{},
 		values:      fasthttpsession.NewDefaultCCMap(),
+		locker:      fasthttpsession.NewSessionLocker(),
 		maxLifeTime: 0,
+		users:       map[string]map[string]struct{}{},
 	}
 }
 
@@ -40,6 +52,16 @@ func (mp *Provider) Init(lifeTime int64, memoryConfig fasthttpsession.ProviderCo
 	return nil
 }
 
+// set the absolute session lifetime(s), 0 means no limit
+func (mp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	mp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the func called with the sessions expired by GC
+func (mp *Provider) SetExpireFunc(expireFunc func(sessionId string)) {
+	mp.expireFunc = expireFunc
+}
+
 // need gc
 func (mp *Provider) NeedGC() bool {
 	return true
@@ -48,22 +70,30 @@ func (mp *Provider) NeedGC() bool {
 // session garbage collection
 func (mp *Provider) GC() {
 	for sessionId, value := range mp.values.GetAll() {
-		if time.Now().Unix() >= value.(*Store).lastActiveTime+mp.maxLifeTime {
+		if mp.isExpired(value.(*Store), time.Now().Unix()) {
 			// destroy session sessionId
 			mp.Destroy(sessionId)
-			return
+			if mp.expireFunc != nil {
+				mp.expireFunc(sessionId)
+			}
 		}
 	}
 }
 
 // read session store by session id
 func (mp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return mp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+func (mp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
 	memStore := mp.values.Get(sessionId)
 	if memStore != nil {
 		return memStore.(*Store), nil
 	}
 
 	newMemStore := NewMemoryStore(sessionId)
+	newMemStore.provider = mp
 	mp.values.Set(sessionId, newMemStore)
 
 	return newMemStore, nil
@@ -71,18 +101,26 @@ func (mp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 
 // regenerate session
 func (mp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return mp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+func (mp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
 	memStoreInter := mp.values.Get(oldSessionId)
 	if memStoreInter != nil {
 		memStore := memStoreInter.(*Store)
 		// insert new session store
-		newMemStore := NewMemoryStoreData(sessionId, memStore.GetAll())
+		newMemStore := NewMemoryStoreData(sessionId, memStore.Export())
+		newMemStore.provider = mp
 		mp.values.Set(sessionId, newMemStore)
+		mp.indexUser(newMemStore.UserID(), sessionId)
 		// delete old session store
-		mp.values.Delete(oldSessionId)
+		mp.DestroyContext(ctx, oldSessionId)
 		return newMemStore, nil
 	}
 
 	memStore := NewMemoryStore(sessionId)
+	memStore.provider = mp
 	mp.values.Set(sessionId, memStore)
 
 	return memStore, nil
@@ -90,16 +128,130 @@ func (mp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttps
 
 // destroy session by sessionId
 func (mp *Provider) Destroy(sessionId string) error {
+	return mp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (mp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	if memStore := mp.values.Get(sessionId); memStore != nil {
+		mp.unindexUser(memStore.(*Store).UserID(), sessionId)
+	}
 	mp.values.Delete(sessionId)
 	return nil
 }
 
+// session ids of userID
+func (mp *Provider) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	mp.usersLock.Lock()
+	defer mp.usersLock.Unlock()
+
+	sessionIds := make([]string, 0, len(mp.users[userID]))
+	for sessionId := range mp.users[userID] {
+		// the session may have been given to another user since it was indexed
+		memStore := mp.values.Get(sessionId)
+		if memStore == nil || memStore.(*Store).UserID() != userID {
+			delete(mp.users[userID], sessionId)
+			continue
+		}
+		sessionIds = append(sessionIds, sessionId)
+	}
+	if len(mp.users[userID]) == 0 {
+		delete(mp.users, userID)
+	}
+	return sessionIds, nil
+}
+
+// destroy all the sessions of userID
+func (mp *Provider) DestroyUserSessions(ctx context.Context, userID string) error {
+	sessionIds, err := mp.UserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, sessionId := range sessionIds {
+		mp.DestroyContext(ctx, sessionId)
+	}
+	return nil
+}
+
+// session exists by sessionId
+func (mp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	return mp.values.Get(sessionId) != nil, nil
+}
+
+// lock session by sessionId
+func (mp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	return mp.locker.Lock(ctx, sessionId)
+}
+
+// scan the sessions in session id order, the cursor is the last session id
+func (mp *Provider) Scan(ctx context.Context, cursor string, limit int) ([]fasthttpsession.SessionInfo, string, error) {
+	values := mp.values.GetAll()
+	sessionIds := make([]string, 0, len(values))
+	now := time.Now().Unix()
+	for sessionId, value := range values {
+		// the expired sessions not collected by GC yet are skipped
+		if sessionId > cursor && !mp.isExpired(value.(*Store), now) {
+			sessionIds = append(sessionIds, sessionId)
+		}
+	}
+	sort.Strings(sessionIds)
+
+	nextCursor := ""
+	if len(sessionIds) > limit {
+		sessionIds = sessionIds[:limit]
+		nextCursor = sessionIds[limit-1]
+	}
+	infos := make([]fasthttpsession.SessionInfo, 0, len(sessionIds))
+	for _, sessionId := range sessionIds {
+		infos = append(infos, values[sessionId].(*Store).info())
+	}
+	return infos, nextCursor, nil
+}
+
+// peek the session data, the data is not serialized, its size is 0
+func (mp *Provider) Peek(ctx context.Context, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	memStore := mp.values.Get(sessionId)
+	if memStore == nil || mp.isExpired(memStore.(*Store), time.Now().Unix()) {
+		return fasthttpsession.SessionInfo{}, nil, fasthttpsession.ErrSessionNotFound
+	}
+	return memStore.(*Store).info(), memStore.(*Store).GetAll(), nil
+}
+
 // session values count
 func (mp *Provider) Count() int {
 	return mp.values.Count()
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, NewProvider())
+// session reached its idle lifetime or the absolute lifetime at now
+func (mp *Provider) isExpired(store *Store, now int64) bool {
+	return now >= store.lastActive()+store.IdleLifetime(mp.maxLifeTime) ||
+		(mp.absoluteLifeTime > 0 && now >= store.CreatedAt().Unix()+mp.absoluteLifeTime)
+}
+
+// add sessionId to the user id index
+func (mp *Provider) indexUser(userID string, sessionId string) {
+	if userID == "" {
+		return
+	}
+	mp.usersLock.Lock()
+	defer mp.usersLock.Unlock()
+
+	if mp.users[userID] == nil {
+		mp.users[userID] = map[string]struct{}{}
+	}
+	mp.users[userID][sessionId] = struct{}{}
+}
+
+// remove sessionId from the user id index
+func (mp *Provider) unindexUser(userID string, sessionId string) {
+	if userID == "" {
+		return
+	}
+	mp.usersLock.Lock()
+	defer mp.usersLock.Unlock()
+
+	delete(mp.users[userID], sessionId)
+	if len(mp.users[userID]) == 0 {
+		delete(mp.users, userID)
+	}
 }
@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+)
+
+// new memory provider of lifeTime(s)
+func newTestProvider(t *testing.T, lifeTime int64) *Provider {
+	t.Helper()
+	provider := NewProvider()
+	if err := provider.Init(lifeTime, &Config{}); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+// read and save the session of userID
+func saveTestSession(t *testing.T, provider *Provider, sessionId string, userID string) fasthttpsession.SessionStore {
+	t.Helper()
+	sessionStore, err := provider.ReadStore(sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStore.SetUserID(userID)
+	if err := sessionStore.(*Store).SaveContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sessionStore
+}
+
+func TestUserSessions(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, provider *Provider)
+		want   map[string][]string
+	}{
+		{
+			name:   "indexed on save",
+			change: func(t *testing.T, provider *Provider) {},
+			want:   map[string][]string{"alice": {"s1", "s2"}, "bob": {"s3"}},
+		},
+		{
+			name: "session given to another user",
+			change: func(t *testing.T, provider *Provider) {
+				saveTestSession(t, provider, "s2", "bob")
+			},
+			want: map[string][]string{"alice": {"s1"}, "bob": {"s2", "s3"}},
+		},
+		{
+			name: "user id removed",
+			change: func(t *testing.T, provider *Provider) {
+				saveTestSession(t, provider, "s3", "")
+			},
+			want: map[string][]string{"alice": {"s1", "s2"}, "bob": {}},
+		},
+		{
+			name: "destroyed session",
+			change: func(t *testing.T, provider *Provider) {
+				provider.Destroy("s1")
+			},
+			want: map[string][]string{"alice": {"s2"}, "bob": {"s3"}},
+		},
+		{
+			name: "regenerated session",
+			change: func(t *testing.T, provider *Provider) {
+				if _, err := provider.Regenerate("s1", "s4"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string][]string{"alice": {"s2", "s4"}, "bob": {"s3"}},
+		},
+		{
+			name: "destroyed user sessions",
+			change: func(t *testing.T, provider *Provider) {
+				if err := provider.DestroyUserSessions(context.Background(), "alice"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string][]string{"alice": {}, "bob": {"s3"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestProvider(t, 60)
+			saveTestSession(t, provider, "s1", "alice")
+			saveTestSession(t, provider, "s2", "alice")
+			saveTestSession(t, provider, "s3", "bob")
+			test.change(t, provider)
+
+			for userID, want := range test.want {
+				sessionIds, err := provider.UserSessions(context.Background(), userID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(sessionIds)
+				if len(sessionIds) != len(want) {
+					t.Fatalf("UserSessions(%s) = %v, want %v", userID, sessionIds, want)
+				}
+				for i := range want {
+					if sessionIds[i] != want[i] {
+						t.Fatalf("UserSessions(%s) = %v, want %v", userID, sessionIds, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// set the session created time(unix) in its meta data
+func setTestCreatedAt(store *Store, createdAt int64) {
+	data := store.Export()
+	data["_meta_"].(map[string]interface{})["created"] = createdAt
+	store.Init(store.GetSessionId(), data)
+}
+
+func TestScanPeekExpired(t *testing.T) {
+	tests := []struct {
+		name             string
+		absoluteLifeTime int64
+		change           func(store *Store)
+		found            bool
+	}{
+		{
+			name:   "active session",
+			change: func(store *Store) {},
+			found:  true,
+		},
+		{
+			name: "idle lifetime reached",
+			change: func(store *Store) {
+				store.lastActiveTime -= 120
+			},
+		},
+		{
+			name: "session ttl reached",
+			change: func(store *Store) {
+				store.SetTTL(30 * time.Second)
+				store.lastActiveTime -= 30
+			},
+		},
+		{
+			name:             "absolute lifetime reached",
+			absoluteLifeTime: 60,
+			change: func(store *Store) {
+				setTestCreatedAt(store, time.Now().Unix()-120)
+			},
+		},
+		{
+			name:             "absolute lifetime not reached",
+			absoluteLifeTime: 600,
+			change: func(store *Store) {
+				setTestCreatedAt(store, time.Now().Unix()-120)
+			},
+			found: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestProvider(t, 60)
+			provider.SetAbsoluteLifetime(test.absoluteLifeTime)
+			test.change(saveTestSession(t, provider, "s1", "").(*Store))
+
+			infos, _, err := provider.Scan(context.Background(), "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := len(infos) == 1; found != test.found {
+				t.Fatalf("Scan = %v, want found %v", infos, test.found)
+			}
+			_, _, err = provider.Peek(context.Background(), "s1")
+			if test.found && err != nil {
+				t.Fatalf("Peek error: %v", err)
+			}
+			if !test.found && !errors.Is(err, fasthttpsession.ErrSessionNotFound) {
+				t.Fatalf("Peek error = %v, want ErrSessionNotFound", err)
+			}
+		})
+	}
+}
+
+func TestGCExpireFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		expired []string
+	}{
+		{"no expired session", nil},
+		{"one expired session", []string{"s2"}},
+		{"every expired session", []string{"s1", "s3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestProvider(t, 60)
+			var got []string
+			provider.SetExpireFunc(func(sessionId string) {
+				got = append(got, sessionId)
+			})
+			for _, sessionId := range []string{"s1", "s2", "s3", "s4"} {
+				saveTestSession(t, provider, sessionId, "")
+			}
+			for _, sessionId := range test.expired {
+				provider.values.Get(sessionId).(*Store).lastActiveTime -= 120
+			}
+			provider.GC()
+
+			sort.Strings(got)
+			if len(got) != len(test.expired) {
+				t.Fatalf("expired sessions = %v, want %v", got, test.expired)
+			}
+			for i := range got {
+				if got[i] != test.expired[i] {
+					t.Fatalf("expired sessions = %v, want %v", got, test.expired)
+				}
+			}
+			if count := provider.Count(); count != 4-len(test.expired) {
+				t.Fatalf("Count() = %d, want %d", count, 4-len(test.expired))
+			}
+		})
+	}
+}
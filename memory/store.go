@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -26,15 +27,44 @@ func NewMemoryStoreData(sessionId string, data map[string]interface{}) *Store {
 
 type Store struct {
 	fasthttpsession.Store
+	provider       *Provider
 	lock           sync.RWMutex
 	lastActiveTime int64
 }
 
 // save store
 func (ms *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return ms.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+func (ms *Store) SaveContext(ctx context.Context) error {
 	ms.lock.Lock()
 	defer ms.lock.Unlock()
 
 	ms.lastActiveTime = time.Now().Unix()
+	if ms.provider != nil {
+		ms.provider.indexUser(ms.UserID(), ms.GetSessionId())
+	}
 	return nil
 }
+
+// session info with the last save time
+func (ms *Store) info() fasthttpsession.SessionInfo {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+
+	info := ms.Info()
+	if ms.lastActiveTime > 0 {
+		info.LastActiveAt = time.Unix(ms.lastActiveTime, 0)
+	}
+	return info
+}
+
+// last save time(unix), 0 if the store was never saved
+func (ms *Store) lastActive() int64 {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+
+	return ms.lastActiveTime
+}
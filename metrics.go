@@ -0,0 +1,65 @@
+package fasthttpsession
+
+import (
+	"time"
+)
+
+// session events counted by Metrics.IncSessions
+const (
+	MetricSessionCreated     = "created"
+	MetricSessionDestroyed   = "destroyed"
+	MetricSessionExpired     = "expired"
+	MetricSessionRegenerated = "regenerated"
+)
+
+// Metrics records the session and provider metrics, see NewPrometheusMetrics.
+// provider is the name of the provider config, e.g. "redis".
+type Metrics interface {
+	// a session event, MetricSessionCreated, MetricSessionDestroyed, ...
+	IncSessions(provider string, event string)
+
+	// a provider read of a session store, size is the serialized data size
+	ObserveRead(provider string, duration time.Duration, size int)
+
+	// a save of a session store, size is the serialized data size
+	ObserveSave(provider string, duration time.Duration, size int)
+
+	// a provider garbage collection
+	ObserveGC(provider string, duration time.Duration)
+
+	// count is the provider Count, it may query the backend, cache its result between two collects.
+	// the count funcs of the sessions sharing a provider label are summed.
+	SetCountFunc(provider string, count func() int)
+}
+
+// provider label of the metrics, Config.MetricsLabel or the provider config name
+func (s *Session) metricsLabel() string {
+	if s.config.MetricsLabel != "" {
+		return s.config.MetricsLabel
+	}
+	return s.providerName
+}
+
+func (s *Session) incSessions(event string) {
+	if s.config.Metrics != nil {
+		s.config.Metrics.IncSessions(s.metricsLabel(), event)
+	}
+}
+
+func (s *Session) observeRead(start time.Time, sessionStore SessionStore) {
+	if s.config.Metrics != nil && sessionStore != nil {
+		s.config.Metrics.ObserveRead(s.metricsLabel(), time.Since(start), sessionStore.Size())
+	}
+}
+
+func (s *Session) observeSave(start time.Time, sessionStore SessionStore) {
+	if s.config.Metrics != nil {
+		s.config.Metrics.ObserveSave(s.metricsLabel(), time.Since(start), sessionStore.Size())
+	}
+}
+
+func (s *Session) observeGC(start time.Time) {
+	if s.config.Metrics != nil {
+		s.config.Metrics.ObserveGC(s.metricsLabel(), time.Since(start))
+	}
+}
@@ -0,0 +1,83 @@
+package fasthttpsession
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+var ErrNoMiddleware = errors.New("session store error, request is not handled by the session middleware")
+
+// session state of a request handled by the middleware
+type requestSession struct {
+	store  SessionStore
+	loaded bool
+}
+
+// Middleware returns a fasthttp.RequestHandler which starts the session lazily,
+// on the first LoadStore call of next, and saves it after next returns.
+// save errors are passed to Config.ErrorHandlerFunc, a save conflict as an
+// error wrapping ErrConflict, the concurrent write is kept.
+// A session lock held by the request is released after the save, or if next panics.
+func (s *Session) Middleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		state := &requestSession{}
+		ctx.SetUserValue(s.userValueKey, state)
+		defer ctx.RemoveUserValue(s.userValueKey)
+		defer func() {
+			err := s.Unlock(ctx)
+			if err != nil {
+				s.logError("session unlock error", "unlock", s.GetSessionId(ctx), err)
+			}
+		}()
+
+		next(ctx)
+
+		if !state.loaded {
+			return
+		}
+		if s.config.NeedStoreInMap {
+			defer s.RemoveSessionStoreWithCtx(ctx)
+		}
+		err := s.Save(ctx, state.store)
+		if err != nil {
+			s.config.ErrorHandler(ctx, fmt.Errorf("Error when save session data : %w", err))
+		}
+	}
+}
+
+// LoadStore returns the session store of a request handled by the middleware,
+// the session is started by the first call.
+func (s *Session) LoadStore(ctx *fasthttp.RequestCtx) (SessionStore, error) {
+	state := s.requestSession(ctx)
+	if state == nil {
+		return nil, ErrNoMiddleware
+	}
+	if state.loaded {
+		return state.store, nil
+	}
+	return s.Start(ctx)
+}
+
+// get the middleware session state of the request, nil without middleware
+func (s *Session) requestSession(ctx *fasthttp.RequestCtx) *requestSession {
+	state, _ := ctx.UserValue(s.userValueKey).(*requestSession)
+	return state
+}
+
+// track the store that the middleware saves after the request
+func (s *Session) trackStore(ctx *fasthttp.RequestCtx, sessionStore SessionStore) {
+	if state := s.requestSession(ctx); state != nil {
+		state.store = sessionStore
+		state.loaded = true
+	}
+}
+
+// untrack the store of a destroyed session, it must not be saved again
+func (s *Session) untrackStore(ctx *fasthttp.RequestCtx) {
+	if state := s.requestSession(ctx); state != nil {
+		state.store = nil
+		state.loaded = false
+	}
+}
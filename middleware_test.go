@@ -0,0 +1,164 @@
+package fasthttpsession_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/brunohass/fasthttpsession/file"
+	"github.com/brunohass/fasthttpsession/memory"
+	"github.com/valyala/fasthttp"
+)
+
+// new session of a memory provider
+func newTestSession(t *testing.T, config *fasthttpsession.Config) *fasthttpsession.Session {
+	t.Helper()
+	return newTestProviderSession(t, config, memory.NewProvider(), &memory.Config{})
+}
+
+// new session of provider
+func newTestProviderSession(t *testing.T, config *fasthttpsession.Config, provider fasthttpsession.Provider, providerConfig fasthttpsession.ProviderConfig) *fasthttpsession.Session {
+	t.Helper()
+	config.Logger = fasthttpsession.NewNopLogger()
+	session := fasthttpsession.NewSession(config)
+	err := session.SetProvider(provider, providerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close(context.Background()) })
+	return session
+}
+
+// new request ctx sending the session id cookie, if not empty
+func newTestCtx(cookieName string, sessionId string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&fasthttp.Request{}, nil, nil)
+	if sessionId != "" {
+		ctx.Request.Header.SetCookie(cookieName, sessionId)
+	}
+	return ctx
+}
+
+// start and save a new session, return its id
+func newTestSessionId(t *testing.T, session *fasthttpsession.Session) string {
+	t.Helper()
+	ctx := newTestCtx("", "")
+	sessionStore, err := session.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Save(ctx, sessionStore); err != nil {
+		t.Fatal(err)
+	}
+	return sessionStore.GetSessionId()
+}
+
+func TestMiddlewareUnlockOnPanic(t *testing.T) {
+	config := fasthttpsession.NewDefaultConfig()
+	config.LockTimeout = 100 * time.Millisecond
+	session := newTestSession(t, config)
+	sessionId := newTestSessionId(t, session)
+
+	handler := session.Middleware(func(ctx *fasthttp.RequestCtx) {
+		if _, err := session.StartLocked(ctx); err != nil {
+			t.Errorf("StartLocked error: %v", err)
+		}
+		panic("handler panic")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("handler did not panic")
+			}
+		}()
+		handler(newTestCtx(config.CookieName, sessionId))
+	}()
+
+	ctx := newTestCtx(config.CookieName, sessionId)
+	if _, err := session.StartLocked(ctx); err != nil {
+		t.Fatalf("StartLocked after a panic error: %v", err)
+	}
+	if err := session.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMiddlewareSaveConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		// a concurrent request changes the session while the handler runs
+		concurrent func(t *testing.T, session *fasthttpsession.Session, provider *file.Provider, sessionId string)
+		wantErr    bool
+		wantExists bool
+	}{
+		{
+			name: "concurrent save",
+			concurrent: func(t *testing.T, session *fasthttpsession.Session, provider *file.Provider, sessionId string) {
+				other := newTestCtx(fasthttpsession.NewDefaultConfig().CookieName, sessionId)
+				otherStore, err := session.Start(other)
+				if err != nil {
+					t.Fatal(err)
+				}
+				otherStore.Set("a", "a")
+				if err := session.Save(other, otherStore); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr:    true,
+			wantExists: true,
+		},
+		{
+			name: "concurrent destroy",
+			concurrent: func(t *testing.T, session *fasthttpsession.Session, provider *file.Provider, sessionId string) {
+				if err := provider.Destroy(sessionId); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fasthttpsession.NewDefaultConfig()
+			var handlerErr error
+			config.ErrorHandlerFunc = func(ctx *fasthttp.RequestCtx, err error) {
+				handlerErr = err
+			}
+			provider := file.NewProvider()
+			session := newTestProviderSession(t, config, provider, &file.Config{SavePath: t.TempDir()})
+			sessionId := newTestSessionId(t, session)
+
+			handler := session.Middleware(func(ctx *fasthttp.RequestCtx) {
+				sessionStore, err := session.LoadStore(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sessionStore.Set("b", "b")
+				test.concurrent(t, session, provider, sessionId)
+			})
+			handler(newTestCtx(config.CookieName, sessionId))
+
+			if gotErr := errors.Is(handlerErr, fasthttpsession.ErrConflict); gotErr != test.wantErr {
+				t.Fatalf("ErrorHandler error = %v, want ErrConflict %v", handlerErr, test.wantErr)
+			}
+			exists, err := provider.Exists(context.Background(), sessionId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != test.wantExists {
+				t.Fatalf("session exists = %v, want %v", exists, test.wantExists)
+			}
+			if !exists {
+				return
+			}
+			_, data, err := provider.Peek(context.Background(), sessionId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data["a"] != "a" || data["b"] != nil {
+				t.Fatalf("session data = %v, want the concurrent write", data)
+			}
+		})
+	}
+}
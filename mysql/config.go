@@ -45,7 +45,7 @@ type Config struct {
 	// mysql max free idle
 	SetMaxIdleConn int
 
-	// mysql max open idle
+	// mysql max open conns, a locked session holds one until unlock
 	SetMaxOpenConn int
 
 	// session value serialize func
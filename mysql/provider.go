@@ -1,8 +1,12 @@
 package mysql
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -17,23 +21,32 @@ import (
 //    `session_id` varchar(64) NOT NULL DEFAULT '' COMMENT 'Session id',
 //    `contents` TEXT NOT NULL COMMENT 'Session data',
 //    `last_active` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'Last active time',
+//    `created_at` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'Create time',
+//    `lifetime` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'Session idle lifetime, 0 is the provider lifetime',
+//    `version` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'Session version',
+//    `user_id` varchar(64) NOT NULL DEFAULT '' COMMENT 'User id',
 //    PRIMARY KEY (`session_id`),
-//    KEY `last_active` (`last_active`)
+//    KEY `last_active` (`last_active`),
+//    KEY `created_at` (`created_at`),
+//    KEY `user_id` (`user_id`)
 // ) ENGINE=MyISAM DEFAULT CHARSET=utf8 COMMENT='session table';
 //
 
 const ProviderName = "mysql"
 
-var (
-	provider = NewProvider()
-	encrypt  = fasthttpsession.NewEncrypt()
-)
+// columns added to the session table since its first version
+var requiredColumns = []string{"created_at", "lifetime", "version", "user_id"}
+
+var encrypt = fasthttpsession.NewEncrypt()
 
 type Provider struct {
-	config      *Config
-	values      *fasthttpsession.CCMap
-	sessionDao  *sessionDao
-	maxLifeTime int64
+	config           *Config
+	values           *fasthttpsession.CCMap
+	sessionDao       *sessionDao
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	expireFunc       func(sessionId string)
+	logger           fasthttpsession.Logger
 }
 
 // new mysql provider
@@ -42,6 +55,7 @@ func NewProvider() *Provider {
 		config:     &Config{},
 		values:     fasthttpsession.NewDefaultCCMap(),
 		sessionDao: &sessionDao{},
+		logger:     fasthttpsession.NewNopLogger(),
 	}
 }
 
@@ -74,11 +88,38 @@ func (mp *Provider) Init(lifeTime int64, mysqlConfig fasthttpsession.ProviderCon
 	if err != nil {
 		return err
 	}
-	sessionDao.mysqlConn.SetMaxOpenConns(mp.config.SetMaxIdleConn)
+	sessionDao.mysqlConn.SetMaxOpenConns(mp.config.SetMaxOpenConn)
 	sessionDao.mysqlConn.SetMaxIdleConns(mp.config.SetMaxIdleConn)
 
 	mp.sessionDao = sessionDao
-	return sessionDao.mysqlConn.Ping()
+	err = sessionDao.mysqlConn.Ping()
+	if err != nil {
+		return err
+	}
+	// a table created by a previous version misses the new columns, see the README upgrade notes
+	missingColumns, err := sessionDao.getMissingColumns(context.Background(), requiredColumns)
+	if err != nil {
+		return err
+	}
+	if len(missingColumns) > 0 {
+		return fmt.Errorf("session mysql provider init error, table %s misses the columns %s, see the table structure", mp.config.TableName, strings.Join(missingColumns, ", "))
+	}
+	return nil
+}
+
+// set the absolute session lifetime(s), 0 means no limit
+func (mp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	mp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the func called with the sessions expired by GC
+func (mp *Provider) SetExpireFunc(expireFunc func(sessionId string)) {
+	mp.expireFunc = expireFunc
+}
+
+// set the logger of the failures which can not be returned, in GC
+func (mp *Provider) SetLogger(logger fasthttpsession.Logger) {
+	mp.logger = logger
 }
 
 // not need gc
@@ -88,25 +129,47 @@ func (mp *Provider) NeedGC() bool {
 
 // session mysql provider not need garbage collection
 func (mp *Provider) GC() {
-	mp.sessionDao.deleteSessionByMaxLifeTime(mp.maxLifeTime)
+	if mp.expireFunc != nil {
+		mp.gcExpired(context.Background())
+		return
+	}
+	_, err := mp.sessionDao.deleteSessionByMaxLifeTime(context.Background(), mp.maxLifeTime)
+	if err != nil {
+		mp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+	}
+	if mp.absoluteLifeTime > 0 {
+		_, err = mp.sessionDao.deleteSessionByAbsoluteLifeTime(context.Background(), mp.absoluteLifeTime)
+		if err != nil {
+			mp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		}
+	}
 }
 
 // read session store by session id
 func (mp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return mp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+func (mp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
 
-	sessionValue, err := mp.sessionDao.getSessionBySessionId(sessionId)
+	sessionValue, err := mp.sessionDao.getSessionBySessionId(ctx, sessionId)
 	if err != nil {
 		return nil, err
 	}
 	if len(sessionValue) == 0 {
-		_, err := mp.sessionDao.insert(sessionId, "", time.Now().Unix())
+		_, err := mp.sessionDao.insert(ctx, sessionId, "", time.Now().Unix(), time.Now().Unix(), 0, "")
 		if err != nil {
 			return nil, err
 		}
-		return NewMysqlStore(sessionId), nil
+		return NewMysqlStore(mp, sessionId), nil
 	}
+	// the version column is the session version
+	version, _ := strconv.ParseInt(string(sessionValue["version"]), 10, 64)
 	if len(sessionValue["contents"]) == 0 {
-		return NewMysqlStore(sessionId), nil
+		store := NewMysqlStore(mp, sessionId)
+		store.SetVersion(version)
+		return store, nil
 	}
 
 	data, err := mp.config.UnSerializeFunc(sessionValue["contents"])
@@ -114,51 +177,168 @@ func (mp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 		return nil, err
 	}
 
-	return NewMysqlStoreData(sessionId, data), nil
+	store := NewMysqlStoreData(mp, sessionId, data)
+	store.SetVersion(version)
+	store.SetSize(len(sessionValue["contents"]))
+	return store, nil
 }
 
 // regenerate session
 func (mp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return mp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+func (mp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
 
-	sessionValue, err := mp.sessionDao.getSessionBySessionId(oldSessionId)
+	sessionValue, err := mp.sessionDao.getSessionBySessionId(ctx, oldSessionId)
 	if err != nil {
 		return nil, err
 	}
 	if len(sessionValue) == 0 {
 		// old sessionId not exists, insert new sessionId
-		_, err := mp.sessionDao.insert(sessionId, "", time.Now().Unix())
+		_, err := mp.sessionDao.insert(ctx, sessionId, "", time.Now().Unix(), time.Now().Unix(), 0, "")
 		if err != nil {
 			return nil, err
 		}
-		return NewMysqlStore(sessionId), nil
+		return NewMysqlStore(mp, sessionId), nil
 	}
 
 	// delete old session
-	_, err = mp.sessionDao.deleteBySessionId(oldSessionId)
+	_, err = mp.sessionDao.deleteBySessionId(ctx, oldSessionId)
 	if err != nil {
 		return nil, err
 	}
-	// insert new session
-	_, err = mp.sessionDao.insert(sessionId, string(sessionValue["contents"]), time.Now().Unix())
+	// insert new session, keep the create time, lifetime and user id
+	createdTime, _ := strconv.ParseInt(string(sessionValue["created_at"]), 10, 64)
+	if createdTime == 0 {
+		createdTime = time.Now().Unix()
+	}
+	lifeTime, _ := strconv.ParseInt(string(sessionValue["lifetime"]), 10, 64)
+	_, err = mp.sessionDao.insert(ctx, sessionId, string(sessionValue["contents"]), time.Now().Unix(), createdTime, lifeTime, string(sessionValue["user_id"]))
 	if err != nil {
 		return nil, err
 	}
 
-	return mp.ReadStore(sessionId)
+	return mp.ReadStoreContext(ctx, sessionId)
 }
 
 // destroy session by sessionId
 func (mp *Provider) Destroy(sessionId string) error {
-	_, err := mp.sessionDao.deleteBySessionId(sessionId)
+	return mp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (mp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	_, err := mp.sessionDao.deleteBySessionId(ctx, sessionId)
 	return err
 }
 
+// lock session by sessionId, held on a dedicated db connection until unlock
+func (mp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	return mp.sessionDao.lockSession(ctx, sessionId)
+}
+
+// session exists by sessionId
+func (mp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	return mp.sessionDao.sessionIdIsExists(ctx, sessionId)
+}
+
+// session ids of userID
+func (mp *Provider) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	return mp.sessionDao.getSessionIdsByUserId(ctx, userID, mp.maxLifeTime, mp.absoluteLifeTime)
+}
+
+// destroy all the sessions of userID
+func (mp *Provider) DestroyUserSessions(ctx context.Context, userID string) error {
+	_, err := mp.sessionDao.deleteByUserId(ctx, userID)
+	return err
+}
+
+// scan the sessions in session id order, the cursor is the last session id
+func (mp *Provider) Scan(ctx context.Context, cursor string, limit int) ([]fasthttpsession.SessionInfo, string, error) {
+	rows, err := mp.sessionDao.getSessionsAfterSessionId(ctx, cursor, limit, mp.maxLifeTime, mp.absoluteLifeTime)
+	if err != nil {
+		return nil, "", err
+	}
+	infos := make([]fasthttpsession.SessionInfo, 0, len(rows))
+	for _, row := range rows {
+		info, _, err := mp.getRowInfo(row)
+		if err != nil {
+			return nil, "", err
+		}
+		infos = append(infos, info)
+	}
+	nextCursor := ""
+	if len(infos) == limit {
+		nextCursor = infos[len(infos)-1].SessionId
+	}
+	return infos, nextCursor, nil
+}
+
+// peek the session data, the last active time is not updated
+func (mp *Provider) Peek(ctx context.Context, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	row, err := mp.sessionDao.getUnexpiredSessionBySessionId(ctx, sessionId, mp.maxLifeTime, mp.absoluteLifeTime)
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	if len(row) == 0 {
+		return fasthttpsession.SessionInfo{}, nil, fasthttpsession.ErrSessionNotFound
+	}
+	return mp.getRowInfo(row)
+}
+
 // session values count
 func (mp *Provider) Count() int {
-	return mp.sessionDao.countSessions()
+	return mp.sessionDao.countSessions(context.Background())
+}
+
+// close the mysql db
+func (mp *Provider) Close() error {
+	if mp.sessionDao.mysqlConn == nil {
+		return nil
+	}
+	return mp.sessionDao.mysqlConn.Close()
+}
+
+// get the session info and data of a session row
+func (mp *Provider) getRowInfo(row map[string][]byte) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if len(row["contents"]) > 0 {
+		var err error
+		data, err = mp.config.UnSerializeFunc(row["contents"])
+		if err != nil {
+			return fasthttpsession.SessionInfo{}, nil, err
+		}
+	}
+	store := &fasthttpsession.Store{}
+	store.Init(string(row["session_id"]), data)
+
+	info := store.Info()
+	createdTime, _ := strconv.ParseInt(string(row["created_at"]), 10, 64)
+	info.CreatedAt = time.Unix(createdTime, 0)
+	lastActiveTime, _ := strconv.ParseInt(string(row["last_active"]), 10, 64)
+	info.LastActiveAt = time.Unix(lastActiveTime, 0)
+	info.Size = len(row["contents"])
+	return info, store.GetAll(), nil
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, provider)
+// delete the expired sessions one by one, to report them to the expire func
+func (mp *Provider) gcExpired(ctx context.Context) {
+	sessionIds, err := mp.sessionDao.getExpiredSessionIds(ctx, mp.maxLifeTime, mp.absoluteLifeTime)
+	if err != nil {
+		mp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		return
+	}
+	for _, sessionId := range sessionIds {
+		// the session may have been saved since it was selected
+		rows, err := mp.sessionDao.deleteExpiredBySessionId(ctx, sessionId, mp.maxLifeTime, mp.absoluteLifeTime)
+		if err != nil {
+			mp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "sessionId", sessionId, "error", err)
+			continue
+		}
+		if rows > 0 {
+			mp.expireFunc(sessionId)
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -10,34 +11,51 @@ import (
 // session mysql store
 
 // new default mysql store
-func NewMysqlStore(sessionId string) *Store {
-	mysqlStore := &Store{}
+func NewMysqlStore(provider *Provider, sessionId string) *Store {
+	mysqlStore := &Store{provider: provider}
 	mysqlStore.Init(sessionId, make(map[string]interface{}))
 	return mysqlStore
 }
 
 // new mysql store data
-func NewMysqlStoreData(sessionId string, data map[string]interface{}) *Store {
-	mysqlStore := &Store{}
+func NewMysqlStoreData(provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	mysqlStore := &Store{provider: provider}
 	mysqlStore.Init(sessionId, data)
 	return mysqlStore
 }
 
 type Store struct {
 	fasthttpsession.Store
+	provider *Provider
 }
 
 // save store
 func (ms *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return ms.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+// an unchanged store only updates the last active time
+// fasthttpsession.ErrConflict is returned if the session version moved since it was read
+func (ms *Store) SaveContext(ctx context.Context) error {
+
+	if !ms.IsDirty() {
+		_, err := ms.provider.sessionDao.updateLastActiveBySessionId(ctx, ms.GetSessionId(), time.Now().Unix())
+		return err
+	}
 
-	b, err := provider.config.SerializeFunc(ms.GetAll())
+	b, err := ms.provider.config.SerializeFunc(ms.Export())
+	if err != nil {
+		return err
+	}
+	rows, err := ms.provider.sessionDao.updateBySessionId(ctx, ms.GetSessionId(), string(b), time.Now().Unix(), int64(ms.TTL()/time.Second), ms.UserID(), ms.Version())
 	if err != nil {
 		return err
 	}
-	session, err := provider.sessionDao.getSessionBySessionId(ms.GetSessionId())
-	if err != nil || len(session) == 0 {
-		return nil
+	if rows == 0 {
+		return fasthttpsession.ErrConflict
 	}
-	_, err = provider.sessionDao.updateBySessionId(ms.GetSessionId(), string(b), time.Now().Unix())
-	return err
+	ms.MarkSaved()
+	ms.SetSize(len(b))
+	return nil
 }
@@ -34,7 +34,7 @@ type Config struct {
 	// postgres max free idle
 	SetMaxIdleConn int
 
-	// postgres max open idle
+	// postgres max open conns, a locked session holds one until unlock
 	SetMaxOpenConn int
 
 	// session value serialize func
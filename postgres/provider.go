@@ -1,8 +1,12 @@
 package postgres
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -17,23 +21,32 @@ import (
 //    `session_id` varchar(64) NOT NULL DEFAULT '',
 //    `contents` TEXT NOT NULL,
 //    `last_active` int(10) NOT NULL DEFAULT '0',
+//    `created_at` int(10) NOT NULL DEFAULT '0',
+//    `lifetime` int(10) NOT NULL DEFAULT '0',
+//    `version` bigint NOT NULL DEFAULT '0',
+//    `user_id` varchar(64) NOT NULL DEFAULT '',
 //    PRIMARY KEY (`session_id`),
 //  )
 //  create index last_active on session (last_active);
+//  create index created_at on session (created_at);
+//  create index user_id on session (user_id);
 //
 
 const ProviderName = "postgres"
 
-var (
-	provider = NewProvider()
-	encrypt  = fasthttpsession.NewEncrypt()
-)
+// columns added to the session table since its first version
+var requiredColumns = []string{"created_at", "lifetime", "version", "user_id"}
+
+var encrypt = fasthttpsession.NewEncrypt()
 
 type Provider struct {
-	config      *Config
-	values      *fasthttpsession.CCMap
-	sessionDao  *sessionDao
-	maxLifeTime int64
+	config           *Config
+	values           *fasthttpsession.CCMap
+	sessionDao       *sessionDao
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	expireFunc       func(sessionId string)
+	logger           fasthttpsession.Logger
 }
 
 // new postgres provider
@@ -42,6 +55,7 @@ func NewProvider() *Provider {
 		config:     &Config{},
 		values:     fasthttpsession.NewDefaultCCMap(),
 		sessionDao: &sessionDao{},
+		logger:     fasthttpsession.NewNopLogger(),
 	}
 }
 
@@ -74,11 +88,38 @@ func (pp *Provider) Init(lifeTime int64, postgresConfig fasthttpsession.Provider
 	if err != nil {
 		return err
 	}
-	sessionDao.postgresConn.SetMaxOpenConns(pp.config.SetMaxIdleConn)
+	sessionDao.postgresConn.SetMaxOpenConns(pp.config.SetMaxOpenConn)
 	sessionDao.postgresConn.SetMaxIdleConns(pp.config.SetMaxIdleConn)
 
 	pp.sessionDao = sessionDao
-	return sessionDao.postgresConn.Ping()
+	err = sessionDao.postgresConn.Ping()
+	if err != nil {
+		return err
+	}
+	// a table created by a previous version misses the new columns, see the README upgrade notes
+	missingColumns, err := sessionDao.getMissingColumns(context.Background(), requiredColumns)
+	if err != nil {
+		return err
+	}
+	if len(missingColumns) > 0 {
+		return fmt.Errorf("session postgres provider init error, table %s misses the columns %s, see the table structure", pp.config.TableName, strings.Join(missingColumns, ", "))
+	}
+	return nil
+}
+
+// set the absolute session lifetime(s), 0 means no limit
+func (pp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	pp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the func called with the sessions expired by GC
+func (pp *Provider) SetExpireFunc(expireFunc func(sessionId string)) {
+	pp.expireFunc = expireFunc
+}
+
+// set the logger of the failures which can not be returned, in GC
+func (pp *Provider) SetLogger(logger fasthttpsession.Logger) {
+	pp.logger = logger
 }
 
 // not need gc
@@ -88,25 +129,47 @@ func (pp *Provider) NeedGC() bool {
 
 // session postgres provider not need garbage collection
 func (pp *Provider) GC() {
-	pp.sessionDao.deleteSessionByMaxLifeTime(pp.maxLifeTime)
+	if pp.expireFunc != nil {
+		pp.gcExpired(context.Background())
+		return
+	}
+	_, err := pp.sessionDao.deleteSessionByMaxLifeTime(context.Background(), pp.maxLifeTime)
+	if err != nil {
+		pp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+	}
+	if pp.absoluteLifeTime > 0 {
+		_, err = pp.sessionDao.deleteSessionByAbsoluteLifeTime(context.Background(), pp.absoluteLifeTime)
+		if err != nil {
+			pp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		}
+	}
 }
 
 // read session store by session id
 func (pp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return pp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+func (pp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
 
-	sessionValue, err := pp.sessionDao.getSessionBySessionId(sessionId)
+	sessionValue, err := pp.sessionDao.getSessionBySessionId(ctx, sessionId)
 	if err != nil {
 		return nil, err
 	}
 	if len(sessionValue) == 0 {
-		_, err := pp.sessionDao.insert(sessionId, "", time.Now().Unix())
+		_, err := pp.sessionDao.insert(ctx, sessionId, "", time.Now().Unix(), time.Now().Unix(), 0, "")
 		if err != nil {
 			return nil, err
 		}
-		return NewPostgresStore(sessionId), nil
+		return NewPostgresStore(pp, sessionId), nil
 	}
+	// the version column is the session version
+	version, _ := strconv.ParseInt(string(sessionValue["version"]), 10, 64)
 	if len(sessionValue["contents"]) == 0 {
-		return NewPostgresStore(sessionId), nil
+		store := NewPostgresStore(pp, sessionId)
+		store.SetVersion(version)
+		return store, nil
 	}
 
 	data, err := pp.config.UnSerializeFunc(sessionValue["contents"])
@@ -114,51 +177,168 @@ func (pp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 		return nil, err
 	}
 
-	return NewPostgresStoreData(sessionId, data), nil
+	store := NewPostgresStoreData(pp, sessionId, data)
+	store.SetVersion(version)
+	store.SetSize(len(sessionValue["contents"]))
+	return store, nil
 }
 
 // regenerate session
 func (pp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return pp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+func (pp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
 
-	sessionValue, err := pp.sessionDao.getSessionBySessionId(oldSessionId)
+	sessionValue, err := pp.sessionDao.getSessionBySessionId(ctx, oldSessionId)
 	if err != nil {
 		return nil, err
 	}
 	if len(sessionValue) == 0 {
 		// old sessionId not exists, insert new sessionId
-		_, err := pp.sessionDao.insert(sessionId, "", time.Now().Unix())
+		_, err := pp.sessionDao.insert(ctx, sessionId, "", time.Now().Unix(), time.Now().Unix(), 0, "")
 		if err != nil {
 			return nil, err
 		}
-		return NewPostgresStore(sessionId), nil
+		return NewPostgresStore(pp, sessionId), nil
 	}
 
 	// delete old session
-	_, err = pp.sessionDao.deleteBySessionId(oldSessionId)
+	_, err = pp.sessionDao.deleteBySessionId(ctx, oldSessionId)
 	if err != nil {
 		return nil, err
 	}
-	// insert new session
-	_, err = pp.sessionDao.insert(sessionId, string(sessionValue["contents"]), time.Now().Unix())
+	// insert new session, keep the create time, lifetime and user id
+	createdTime, _ := strconv.ParseInt(string(sessionValue["created_at"]), 10, 64)
+	if createdTime == 0 {
+		createdTime = time.Now().Unix()
+	}
+	lifeTime, _ := strconv.ParseInt(string(sessionValue["lifetime"]), 10, 64)
+	_, err = pp.sessionDao.insert(ctx, sessionId, string(sessionValue["contents"]), time.Now().Unix(), createdTime, lifeTime, string(sessionValue["user_id"]))
 	if err != nil {
 		return nil, err
 	}
 
-	return pp.ReadStore(sessionId)
+	return pp.ReadStoreContext(ctx, sessionId)
 }
 
 // destroy session by sessionId
 func (pp *Provider) Destroy(sessionId string) error {
-	_, err := pp.sessionDao.deleteBySessionId(sessionId)
+	return pp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (pp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	_, err := pp.sessionDao.deleteBySessionId(ctx, sessionId)
 	return err
 }
 
+// lock session by sessionId, held on a dedicated db connection until unlock
+func (pp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	return pp.sessionDao.lockSession(ctx, sessionId)
+}
+
+// session exists by sessionId
+func (pp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	return pp.sessionDao.sessionIdIsExists(ctx, sessionId)
+}
+
+// session ids of userID
+func (pp *Provider) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	return pp.sessionDao.getSessionIdsByUserId(ctx, userID, pp.maxLifeTime, pp.absoluteLifeTime)
+}
+
+// destroy all the sessions of userID
+func (pp *Provider) DestroyUserSessions(ctx context.Context, userID string) error {
+	_, err := pp.sessionDao.deleteByUserId(ctx, userID)
+	return err
+}
+
+// scan the sessions in session id order, the cursor is the last session id
+func (pp *Provider) Scan(ctx context.Context, cursor string, limit int) ([]fasthttpsession.SessionInfo, string, error) {
+	rows, err := pp.sessionDao.getSessionsAfterSessionId(ctx, cursor, limit, pp.maxLifeTime, pp.absoluteLifeTime)
+	if err != nil {
+		return nil, "", err
+	}
+	infos := make([]fasthttpsession.SessionInfo, 0, len(rows))
+	for _, row := range rows {
+		info, _, err := pp.getRowInfo(row)
+		if err != nil {
+			return nil, "", err
+		}
+		infos = append(infos, info)
+	}
+	nextCursor := ""
+	if len(infos) == limit {
+		nextCursor = infos[len(infos)-1].SessionId
+	}
+	return infos, nextCursor, nil
+}
+
+// peek the session data, the last active time is not updated
+func (pp *Provider) Peek(ctx context.Context, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	row, err := pp.sessionDao.getUnexpiredSessionBySessionId(ctx, sessionId, pp.maxLifeTime, pp.absoluteLifeTime)
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	if len(row) == 0 {
+		return fasthttpsession.SessionInfo{}, nil, fasthttpsession.ErrSessionNotFound
+	}
+	return pp.getRowInfo(row)
+}
+
 // session values count
 func (pp *Provider) Count() int {
-	return pp.sessionDao.countSessions()
+	return pp.sessionDao.countSessions(context.Background())
+}
+
+// close the postgres db
+func (pp *Provider) Close() error {
+	if pp.sessionDao.postgresConn == nil {
+		return nil
+	}
+	return pp.sessionDao.postgresConn.Close()
+}
+
+// get the session info and data of a session row
+func (pp *Provider) getRowInfo(row map[string][]byte) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if len(row["contents"]) > 0 {
+		var err error
+		data, err = pp.config.UnSerializeFunc(row["contents"])
+		if err != nil {
+			return fasthttpsession.SessionInfo{}, nil, err
+		}
+	}
+	store := &fasthttpsession.Store{}
+	store.Init(string(row["session_id"]), data)
+
+	info := store.Info()
+	createdTime, _ := strconv.ParseInt(string(row["created_at"]), 10, 64)
+	info.CreatedAt = time.Unix(createdTime, 0)
+	lastActiveTime, _ := strconv.ParseInt(string(row["last_active"]), 10, 64)
+	info.LastActiveAt = time.Unix(lastActiveTime, 0)
+	info.Size = len(row["contents"])
+	return info, store.GetAll(), nil
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, provider)
+// delete the expired sessions one by one, to report them to the expire func
+func (pp *Provider) gcExpired(ctx context.Context) {
+	sessionIds, err := pp.sessionDao.getExpiredSessionIds(ctx, pp.maxLifeTime, pp.absoluteLifeTime)
+	if err != nil {
+		pp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		return
+	}
+	for _, sessionId := range sessionIds {
+		// the session may have been saved since it was selected
+		rows, err := pp.sessionDao.deleteExpiredBySessionId(ctx, sessionId, pp.maxLifeTime, pp.absoluteLifeTime)
+		if err != nil {
+			pp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "sessionId", sessionId, "error", err)
+			continue
+		}
+		if rows > 0 {
+			pp.expireFunc(sessionId)
+		}
+	}
 }
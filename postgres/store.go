@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -10,34 +11,51 @@ import (
 // session postgres store
 
 // new default postgres store
-func NewPostgresStore(sessionId string) *Store {
-	postgresStore := &Store{}
+func NewPostgresStore(provider *Provider, sessionId string) *Store {
+	postgresStore := &Store{provider: provider}
 	postgresStore.Init(sessionId, make(map[string]interface{}))
 	return postgresStore
 }
 
 // new postgres store data
-func NewPostgresStoreData(sessionId string, data map[string]interface{}) *Store {
-	postgresStore := &Store{}
+func NewPostgresStoreData(provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	postgresStore := &Store{provider: provider}
 	postgresStore.Init(sessionId, data)
 	return postgresStore
 }
 
 type Store struct {
 	fasthttpsession.Store
+	provider *Provider
 }
 
 // save store
 func (ps *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return ps.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+// an unchanged store only updates the last active time
+// fasthttpsession.ErrConflict is returned if the session version moved since it was read
+func (ps *Store) SaveContext(ctx context.Context) error {
+
+	if !ps.IsDirty() {
+		_, err := ps.provider.sessionDao.updateLastActiveBySessionId(ctx, ps.GetSessionId(), time.Now().Unix())
+		return err
+	}
 
-	b, err := provider.config.SerializeFunc(ps.GetAll())
+	b, err := ps.provider.config.SerializeFunc(ps.Export())
+	if err != nil {
+		return err
+	}
+	rows, err := ps.provider.sessionDao.updateBySessionId(ctx, ps.GetSessionId(), string(b), time.Now().Unix(), int64(ps.TTL()/time.Second), ps.UserID(), ps.Version())
 	if err != nil {
 		return err
 	}
-	session, err := provider.sessionDao.getSessionBySessionId(ps.GetSessionId())
-	if err != nil || len(session) == 0 {
-		return nil
+	if rows == 0 {
+		return fasthttpsession.ErrConflict
 	}
-	_, err = provider.sessionDao.updateBySessionId(ps.GetSessionId(), string(b), time.Now().Unix())
-	return err
+	ps.MarkSaved()
+	ps.SetSize(len(b))
+	return nil
 }
@@ -0,0 +1,304 @@
+package fasthttpsession
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// prometheus text exposition format content type
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// default interval between two provider counts
+const defaultCountInterval = 30 * time.Second
+
+var (
+	// provider call and gc duration buckets(s)
+	defaultDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// serialized session data size buckets(bytes)
+	defaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
+// PrometheusMetrics is a Metrics kept in memory and exposed in the Prometheus text format,
+// mount Handler on a route, e.g. router.GET("/metrics", metrics.Handler)
+//
+//	fasthttpsession_sessions_total{provider,event}             counter
+//	fasthttpsession_sessions{provider}                         gauge, the provider Counts, see SetCountInterval
+//	fasthttpsession_operation_duration_seconds{provider,op}    histogram, op is read or save
+//	fasthttpsession_operation_size_bytes{provider,op}          histogram
+//	fasthttpsession_gc_duration_seconds{provider}              histogram
+type PrometheusMetrics struct {
+	lock sync.Mutex
+
+	sessions      map[metricLabels]uint64
+	counts        map[string][]*providerCount
+	countInterval time.Duration
+	opDurations   map[metricLabels]*histogram
+	opSizes       map[metricLabels]*histogram
+	gcDurations   map[metricLabels]*histogram
+}
+
+// metric label values, provider and event or operation
+type metricLabels struct {
+	provider string
+	name     string
+}
+
+// provider Count cached between two scrapes
+type providerCount struct {
+	count      func() int
+	value      uint64
+	updated    time.Time
+	refreshing bool
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// return new PrometheusMetrics
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		sessions:      map[metricLabels]uint64{},
+		counts:        map[string][]*providerCount{},
+		countInterval: defaultCountInterval,
+		opDurations:   map[metricLabels]*histogram{},
+		opSizes:       map[metricLabels]*histogram{},
+		gcDurations:   map[metricLabels]*histogram{},
+	}
+}
+
+// count a session event
+func (pm *PrometheusMetrics) IncSessions(provider string, event string) {
+	pm.lock.Lock()
+	pm.sessions[metricLabels{provider, event}]++
+	pm.lock.Unlock()
+}
+
+// observe a provider read
+func (pm *PrometheusMetrics) ObserveRead(provider string, duration time.Duration, size int) {
+	pm.observeOperation(provider, "read", duration, size)
+}
+
+// observe a session save
+func (pm *PrometheusMetrics) ObserveSave(provider string, duration time.Duration, size int) {
+	pm.observeOperation(provider, "save", duration, size)
+}
+
+// observe a provider gc
+func (pm *PrometheusMetrics) ObserveGC(provider string, duration time.Duration) {
+	pm.lock.Lock()
+	observe(pm.gcDurations, metricLabels{provider: provider}, defaultDurationBuckets, duration.Seconds())
+	pm.lock.Unlock()
+}
+
+// add a session count func of the provider, the counts of a provider are summed
+func (pm *PrometheusMetrics) SetCountFunc(provider string, count func() int) {
+	pm.lock.Lock()
+	pm.counts[provider] = append(pm.counts[provider], &providerCount{count: count})
+	pm.lock.Unlock()
+}
+
+// set the min interval between two provider counts, default 30s.
+// the provider Count may query the backend, e.g. scan the redis keys, the scrapes in between write the last count.
+func (pm *PrometheusMetrics) SetCountInterval(interval time.Duration) {
+	pm.lock.Lock()
+	pm.countInterval = interval
+	pm.lock.Unlock()
+}
+
+// fasthttp handler writing the metrics in the Prometheus text format
+func (pm *PrometheusMetrics) Handler(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType(prometheusContentType)
+	pm.WriteTo(ctx)
+}
+
+// write the metrics in the Prometheus text format
+func (pm *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	pm.refreshCounts()
+
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	counts := make(map[metricLabels]uint64, len(pm.counts))
+	for provider, providerCounts := range pm.counts {
+		for _, count := range providerCounts {
+			if !count.updated.IsZero() {
+				counts[metricLabels{provider: provider}] += count.value
+			}
+		}
+	}
+
+	mw := &metricsWriter{w: bufio.NewWriter(w)}
+	mw.header("fasthttpsession_sessions_total", "counter", "Sessions created, destroyed, expired and regenerated.")
+	for _, labels := range sortedLabels(pm.sessions) {
+		mw.sample("fasthttpsession_sessions_total", labelPairs(labels, "event"), "", float64(pm.sessions[labels]))
+	}
+	mw.header("fasthttpsession_sessions", "gauge", "Sessions kept by the provider.")
+	for _, labels := range sortedLabels(counts) {
+		mw.sample("fasthttpsession_sessions", labelPairs(labels, ""), "", float64(counts[labels]))
+	}
+	mw.histograms("fasthttpsession_operation_duration_seconds", "Provider read and session save latency.", pm.opDurations, "op")
+	mw.histograms("fasthttpsession_operation_size_bytes", "Serialized session data size read and saved.", pm.opSizes, "op")
+	mw.histograms("fasthttpsession_gc_duration_seconds", "Provider garbage collection duration.", pm.gcDurations, "")
+	return mw.n, mw.flush()
+}
+
+// count the providers whose count is older than the count interval,
+// outside the metrics lock, they may query the backend
+func (pm *PrometheusMetrics) refreshCounts() {
+	now := time.Now()
+	pm.lock.Lock()
+	stale := make([]*providerCount, 0, len(pm.counts))
+	for _, providerCounts := range pm.counts {
+		for _, count := range providerCounts {
+			if !count.refreshing && now.Sub(count.updated) >= pm.countInterval {
+				count.refreshing = true
+				stale = append(stale, count)
+			}
+		}
+	}
+	pm.lock.Unlock()
+
+	for _, count := range stale {
+		pm.refreshCount(count)
+	}
+}
+
+// call the count func, a panic of the count func does not stop the next refreshes
+func (pm *PrometheusMetrics) refreshCount(count *providerCount) {
+	defer func() {
+		pm.lock.Lock()
+		count.refreshing = false
+		pm.lock.Unlock()
+	}()
+	value := uint64(count.count())
+	pm.lock.Lock()
+	count.value = value
+	count.updated = time.Now()
+	pm.lock.Unlock()
+}
+
+func (pm *PrometheusMetrics) observeOperation(provider string, op string, duration time.Duration, size int) {
+	labels := metricLabels{provider, op}
+	pm.lock.Lock()
+	observe(pm.opDurations, labels, defaultDurationBuckets, duration.Seconds())
+	observe(pm.opSizes, labels, defaultSizeBuckets, float64(size))
+	pm.lock.Unlock()
+}
+
+// observe value in the histogram of labels, created with buckets
+func observe(histograms map[metricLabels]*histogram, labels metricLabels, buckets []float64, value float64) {
+	h, ok := histograms[labels]
+	if !ok {
+		h = &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		histograms[labels] = h
+	}
+	for i, bucket := range h.buckets {
+		if value <= bucket {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// metrics text writer, keeps the first error
+type metricsWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (mw *metricsWriter) printf(format string, args ...interface{}) {
+	if mw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(mw.w, format, args...)
+	mw.n += int64(n)
+	mw.err = err
+}
+
+func (mw *metricsWriter) flush() error {
+	if mw.err != nil {
+		return mw.err
+	}
+	return mw.w.Flush()
+}
+
+func (mw *metricsWriter) header(name string, metricType string, help string) {
+	mw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// write a sample, extra is an additional label pair, e.g. le="0.5"
+func (mw *metricsWriter) sample(name string, labels string, extra string, value float64) {
+	if extra != "" {
+		if labels != "" {
+			labels += ","
+		}
+		labels += extra
+	}
+	mw.printf("%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+// write the histograms of name, labelName is the label name of metricLabels.name
+func (mw *metricsWriter) histograms(name string, help string, histograms map[metricLabels]*histogram, labelName string) {
+	mw.header(name, "histogram", help)
+	for _, labels := range sortedLabels(histograms) {
+		h := histograms[labels]
+		pairs := labelPairs(labels, labelName)
+		cumulative := uint64(0)
+		for i, bucket := range h.buckets {
+			cumulative += h.counts[i]
+			mw.sample(name+"_bucket", pairs, `le="`+strconv.FormatFloat(bucket, 'f', -1, 64)+`"`, float64(cumulative))
+		}
+		mw.sample(name+"_bucket", pairs, `le="+Inf"`, float64(h.count))
+		mw.sample(name+"_sum", pairs, "", h.sum)
+		mw.sample(name+"_count", pairs, "", float64(h.count))
+	}
+}
+
+// label pairs of labels, name is not written if labelName is empty
+func labelPairs(labels metricLabels, labelName string) string {
+	pairs := `provider="` + escapeLabelValue(labels.provider) + `"`
+	if labelName != "" {
+		pairs += `,` + labelName + `="` + escapeLabelValue(labels.name) + `"`
+	}
+	return pairs
+}
+
+func sortedLabels[V any](m map[metricLabels]V) []metricLabels {
+	labels := make([]metricLabels, 0, len(m))
+	for l := range m {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].provider != labels[j].provider {
+			return labels[i].provider < labels[j].provider
+		}
+		return labels[i].name < labels[j].name
+	})
+	return labels
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
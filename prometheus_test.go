@@ -0,0 +1,193 @@
+package fasthttpsession
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// write the metrics text of pm
+func writeTestMetrics(t *testing.T, pm *PrometheusMetrics) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	n, err := pm.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, wrote %d bytes", n, buf.Len())
+	}
+	return buf.String()
+}
+
+func TestPrometheusMetricsText(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe func(pm *PrometheusMetrics)
+		want    []string
+	}{
+		{
+			name:    "no metrics",
+			observe: func(pm *PrometheusMetrics) {},
+			want: []string{
+				"# HELP fasthttpsession_sessions_total Sessions created, destroyed, expired and regenerated.\n# TYPE fasthttpsession_sessions_total counter\n",
+				"# TYPE fasthttpsession_sessions gauge\n",
+				"# TYPE fasthttpsession_gc_duration_seconds histogram\n",
+			},
+		},
+		{
+			name: "session events",
+			observe: func(pm *PrometheusMetrics) {
+				pm.IncSessions("redis", MetricSessionCreated)
+				pm.IncSessions("redis", MetricSessionCreated)
+				pm.IncSessions("memory", MetricSessionExpired)
+			},
+			want: []string{
+				"fasthttpsession_sessions_total{provider=\"memory\",event=\"expired\"} 1\n" +
+					"fasthttpsession_sessions_total{provider=\"redis\",event=\"created\"} 2\n",
+			},
+		},
+		{
+			name: "session count",
+			observe: func(pm *PrometheusMetrics) {
+				pm.SetCountFunc("memory", func() int { return 3 })
+			},
+			want: []string{"fasthttpsession_sessions{provider=\"memory\"} 3\n"},
+		},
+		{
+			name: "operation histogram",
+			observe: func(pm *PrometheusMetrics) {
+				pm.ObserveRead("redis", 3*time.Millisecond, 100)
+				pm.ObserveRead("redis", 20*time.Second, 2000000)
+			},
+			want: []string{
+				"fasthttpsession_operation_duration_seconds_bucket{provider=\"redis\",op=\"read\",le=\"0.0025\"} 0\n" +
+					"fasthttpsession_operation_duration_seconds_bucket{provider=\"redis\",op=\"read\",le=\"0.005\"} 1\n",
+				"fasthttpsession_operation_duration_seconds_bucket{provider=\"redis\",op=\"read\",le=\"10\"} 1\n" +
+					"fasthttpsession_operation_duration_seconds_bucket{provider=\"redis\",op=\"read\",le=\"+Inf\"} 2\n" +
+					"fasthttpsession_operation_duration_seconds_sum{provider=\"redis\",op=\"read\"} 20.003\n" +
+					"fasthttpsession_operation_duration_seconds_count{provider=\"redis\",op=\"read\"} 2\n",
+				"fasthttpsession_operation_size_bytes_bucket{provider=\"redis\",op=\"read\",le=\"256\"} 1\n",
+				"fasthttpsession_operation_size_bytes_sum{provider=\"redis\",op=\"read\"} 2.0001e+06\n",
+			},
+		},
+		{
+			name: "gc histogram",
+			observe: func(pm *PrometheusMetrics) {
+				pm.ObserveGC("file", time.Second)
+			},
+			want: []string{
+				"fasthttpsession_gc_duration_seconds_bucket{provider=\"file\",le=\"0.5\"} 0\n" +
+					"fasthttpsession_gc_duration_seconds_bucket{provider=\"file\",le=\"1\"} 1\n",
+				"fasthttpsession_gc_duration_seconds_count{provider=\"file\"} 1\n",
+			},
+		},
+		{
+			name: "escaped label value",
+			observe: func(pm *PrometheusMetrics) {
+				pm.IncSessions("a\"b\\c\nd", MetricSessionDestroyed)
+			},
+			want: []string{"fasthttpsession_sessions_total{provider=\"a\\\"b\\\\c\\nd\",event=\"destroyed\"} 1\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pm := NewPrometheusMetrics()
+			test.observe(pm)
+			text := writeTestMetrics(t, pm)
+			for _, want := range test.want {
+				if !strings.Contains(text, want) {
+					t.Fatalf("metrics text misses\n%s\ngot\n%s", want, text)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsCountInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  time.Duration
+		wantCalls int
+		wantCount string
+	}{
+		{"cached between scrapes", time.Hour, 1, "} 1\n"},
+		{"refreshed every scrape", 0, 3, "} 3\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pm := NewPrometheusMetrics()
+			pm.SetCountInterval(test.interval)
+			calls := 0
+			pm.SetCountFunc("redis", func() int {
+				calls++
+				return calls
+			})
+			text := ""
+			for i := 0; i < 3; i++ {
+				text = writeTestMetrics(t, pm)
+			}
+			if calls != test.wantCalls {
+				t.Fatalf("Count calls = %d, want %d", calls, test.wantCalls)
+			}
+			if want := "fasthttpsession_sessions{provider=\"redis\"" + test.wantCount; !strings.Contains(text, want) {
+				t.Fatalf("metrics text misses %q, got\n%s", want, text)
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsCountFuncs(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts map[string][]int
+		want   []string
+	}{
+		{"one provider", map[string][]int{"redis": {2}}, []string{`{provider="redis"} 2`}},
+		{"summed provider counts", map[string][]int{"redis": {2, 3}}, []string{`{provider="redis"} 5`}},
+		{"labels", map[string][]int{"redis": {2}, "redis-admin": {3}}, []string{`{provider="redis"} 2`, `{provider="redis-admin"} 3`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pm := NewPrometheusMetrics()
+			for provider, counts := range test.counts {
+				for _, count := range counts {
+					count := count
+					pm.SetCountFunc(provider, func() int { return count })
+				}
+			}
+			text := writeTestMetrics(t, pm)
+			for _, want := range test.want {
+				if !strings.Contains(text, "fasthttpsession_sessions"+want+"\n") {
+					t.Fatalf("metrics text misses %s, got\n%s", want, text)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsCountPanic(t *testing.T) {
+	pm := NewPrometheusMetrics()
+	calls := 0
+	pm.SetCountFunc("redis", func() int {
+		calls++
+		if calls == 1 {
+			panic("count panic")
+		}
+		return 4
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("count func did not panic")
+			}
+		}()
+		pm.WriteTo(&bytes.Buffer{})
+	}()
+
+	text := writeTestMetrics(t, pm)
+	if calls != 2 || !strings.Contains(text, "fasthttpsession_sessions{provider=\"redis\"} 4\n") {
+		t.Fatalf("count calls = %d, want a refresh after the panic, got\n%s", calls, text)
+	}
+}
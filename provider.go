@@ -1,5 +1,7 @@
 package fasthttpsession
 
+import "context"
+
 type Provider interface {
 	Init(int64, ProviderConfig) error
 	NeedGC() bool
@@ -10,6 +12,71 @@ type Provider interface {
 	Count() int
 }
 
+// ContextProvider is a Provider whose backend calls honour the cancellation
+// and deadline of a context.Context. All bundled providers implement it.
+type ContextProvider interface {
+	Provider
+	ReadStoreContext(context.Context, string) (SessionStore, error)
+	RegenerateContext(context.Context, string, string) (SessionStore, error)
+	DestroyContext(context.Context, string) error
+}
+
+// ClosableProvider is a Provider which holds backend resources, they are
+// released by Session.Close.
+type ClosableProvider interface {
+	Provider
+	Close() error
+}
+
+// AbsoluteLifetimeProvider is a Provider which also expires the sessions
+// older than Config.SessionAbsoluteLifetime, in GC or by backend expiry.
+type AbsoluteLifetimeProvider interface {
+	Provider
+	SetAbsoluteLifetime(int64)
+}
+
+// LockProvider is a Provider which can lock a session across the processes
+// sharing its backend, see Session.StartLocked.
+// Lock blocks until the lock of sessionId is acquired or ctx is done,
+// the returned unlock func releases it.
+type LockProvider interface {
+	Provider
+	Lock(ctx context.Context, sessionId string) (unlock func() error, err error)
+}
+
+// ExistsProvider is a Provider which can tell whether a session exists without
+// creating it, Config.StrictSessionId requires it.
+type ExistsProvider interface {
+	Provider
+	Exists(ctx context.Context, sessionId string) (bool, error)
+}
+
+// SessionIdCheckProvider is a Provider which can not store every session id, e.g. the
+// file provider names the session files by their id. A session id sent by the client
+// which it rejects is replaced by a new session id, like a malformed one.
+type SessionIdCheckProvider interface {
+	Provider
+	ValidSessionId(sessionId string) bool
+}
+
+// UserIndexProvider is a Provider which indexes the sessions by the user id
+// of SessionStore.SetUserID
+type UserIndexProvider interface {
+	Provider
+	UserSessions(ctx context.Context, userID string) ([]string, error)
+	DestroyUserSessions(ctx context.Context, userID string) error
+}
+
+// ScanProvider is a Provider which can browse its sessions without creating or touching them.
+// Scan returns at most about limit sessions after cursor, "" starts the scan,
+// the returned cursor is "" at the end of the scan.
+// Peek returns the session data, ErrSessionNotFound if it does not exist.
+type ScanProvider interface {
+	Provider
+	Scan(ctx context.Context, cursor string, limit int) (infos []SessionInfo, nextCursor string, err error)
+	Peek(ctx context.Context, sessionId string) (SessionInfo, map[string]interface{}, error)
+}
+
 type ProviderConfig interface {
 	Name() string
 }
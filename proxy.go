@@ -0,0 +1,85 @@
+package fasthttpsession
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TrustedProxies are the proxies whose Forwarded and X-Forwarded-Proto headers are trusted,
+// e.g. a TLS-terminating load balancer
+type TrustedProxies struct {
+	nets []*net.IPNet
+}
+
+// return new TrustedProxies of CIDRs or IPs, e.g. "10.0.0.0/8", "192.168.1.10"
+func NewTrustedProxies(proxies ...string) (*TrustedProxies, error) {
+	tp := &TrustedProxies{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, errors.New("session trusted proxies error, invalid IP " + proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			tp.nets = append(tp.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.New("session trusted proxies error, invalid CIDR " + proxy)
+		}
+		tp.nets = append(tp.nets, ipNet)
+	}
+	return tp, nil
+}
+
+// ip is a trusted proxy
+func (tp *TrustedProxies) IsTrusted(ip net.IP) bool {
+	if tp == nil || ip == nil {
+		return false
+	}
+	for _, ipNet := range tp.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// the client request is over TLS, to this server or to a trusted proxy.
+// the proxy protocol is read from the last Forwarded element, else the last
+// X-Forwarded-Proto value, both are added by the proxy closest to this server.
+// a nil TrustedProxies trusts no proxy.
+func (tp *TrustedProxies) IsTLS(ctx *fasthttp.RequestCtx) bool {
+	if ctx.IsTLS() {
+		return true
+	}
+	if !tp.IsTrusted(ctx.RemoteIP()) {
+		return false
+	}
+
+	if forwarded := ctx.Request.Header.Peek("Forwarded"); len(forwarded) > 0 {
+		return strings.EqualFold(forwardedProto(string(forwarded)), "https")
+	}
+	forwardedProtos := strings.Split(string(ctx.Request.Header.Peek("X-Forwarded-Proto")), ",")
+	return strings.EqualFold(strings.TrimSpace(forwardedProtos[len(forwardedProtos)-1]), "https")
+}
+
+// get the proto parameter of the last Forwarded element, RFC 7239
+func forwardedProto(forwarded string) string {
+	elements := strings.Split(forwarded, ",")
+	for _, pair := range strings.Split(elements[len(elements)-1], ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, "proto") {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
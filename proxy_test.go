@@ -0,0 +1,112 @@
+package fasthttpsession
+
+import (
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestNewTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxies   []string
+		wantErr   bool
+		trusted   []string
+		untrusted []string
+	}{
+		{
+			name:      "no proxy",
+			untrusted: []string{"10.0.0.1", "::1"},
+		},
+		{
+			name:      "IPv4 CIDR",
+			proxies:   []string{"10.0.0.0/8"},
+			trusted:   []string{"10.0.0.1", "10.255.255.255"},
+			untrusted: []string{"11.0.0.1", "192.168.1.10"},
+		},
+		{
+			name:      "IPv4 address",
+			proxies:   []string{"192.168.1.10"},
+			trusted:   []string{"192.168.1.10", "::ffff:192.168.1.10"},
+			untrusted: []string{"192.168.1.11"},
+		},
+		{
+			name:      "IPv6 CIDR and address",
+			proxies:   []string{"fd00::/8", "2001:db8::1"},
+			trusted:   []string{"fd12::1", "2001:db8::1"},
+			untrusted: []string{"2001:db8::2", "10.0.0.1"},
+		},
+		{
+			name:    "invalid IP",
+			proxies: []string{"10.0.0.256"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid CIDR",
+			proxies: []string{"10.0.0.0/33"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp, err := NewTrustedProxies(test.proxies...)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("NewTrustedProxies(%v) accepted an invalid proxy", test.proxies)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ip := range test.trusted {
+				if !tp.IsTrusted(net.ParseIP(ip)) {
+					t.Errorf("%s is not trusted", ip)
+				}
+			}
+			for _, ip := range test.untrusted {
+				if tp.IsTrusted(net.ParseIP(ip)) {
+					t.Errorf("%s is trusted", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesIsTLS(t *testing.T) {
+	tp, err := NewTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		proxies  *TrustedProxies
+		remoteIP string
+		headers  map[string]string
+		want     bool
+	}{
+		{"trusted proxy X-Forwarded-Proto", tp, "10.0.0.1", map[string]string{"X-Forwarded-Proto": "https"}, true},
+		{"trusted proxy plain http", tp, "10.0.0.1", map[string]string{"X-Forwarded-Proto": "http"}, false},
+		{"last X-Forwarded-Proto value", tp, "10.0.0.1", map[string]string{"X-Forwarded-Proto": "http, HTTPS"}, true},
+		{"spoofed first X-Forwarded-Proto value", tp, "10.0.0.1", map[string]string{"X-Forwarded-Proto": "https, http"}, false},
+		{"Forwarded", tp, "10.0.0.1", map[string]string{"Forwarded": `for=192.0.2.60;proto="https";by=203.0.113.43`}, true},
+		{"last Forwarded element", tp, "10.0.0.1", map[string]string{"Forwarded": "proto=https, for=192.0.2.60;proto=http"}, false},
+		{"Forwarded over X-Forwarded-Proto", tp, "10.0.0.1", map[string]string{"Forwarded": "proto=http", "X-Forwarded-Proto": "https"}, false},
+		{"untrusted client", tp, "192.0.2.1", map[string]string{"X-Forwarded-Proto": "https"}, false},
+		{"no trusted proxies", nil, "10.0.0.1", map[string]string{"X-Forwarded-Proto": "https"}, false},
+		{"no header", tp, "10.0.0.1", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP(test.remoteIP)}, nil)
+			for key, value := range test.headers {
+				ctx.Request.Header.Set(key, value)
+			}
+			if got := test.proxies.IsTLS(ctx); got != test.want {
+				t.Fatalf("IsTLS = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package redis
 
 // session redis config
 
+const defaultLockExpire = int64(30)
+
 type Config struct {
 
 	// Redis server host
@@ -28,6 +30,10 @@ type Config struct {
 	// sessionId as redis key prefix
 	KeyPrefix string
 
+	// session lock expire, the lock is released if its owner never unlocks it
+	// (s) default 30
+	LockExpire int64
+
 	// session value serialize func
 	SerializeFunc func(data map[string]interface{}) ([]byte, error)
 
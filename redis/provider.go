@@ -1,8 +1,13 @@
 package redis
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/brunohass/fasthttpsession"
 	"github.com/gomodule/redigo/redis"
@@ -12,16 +17,21 @@ import (
 
 const ProviderName = "redis"
 
-var (
-	provider = NewProvider()
-	encrypt  = fasthttpsession.NewEncrypt()
-)
+var encrypt = fasthttpsession.NewEncrypt()
+
+// delete the lock key only if it still holds the token of the owner
+var unlockScript = redis.NewScript(1, `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// add the session id to the user index set, the set lives as long as its longest session
+var indexUserScript = redis.NewScript(1, `redis.call("SADD", KEYS[1], ARGV[1]) if redis.call("TTL", KEYS[1]) < tonumber(ARGV[2]) then redis.call("EXPIRE", KEYS[1], ARGV[2]) end return 1`)
 
 type Provider struct {
-	config      *Config
-	values      *fasthttpsession.CCMap
-	redisPool   *redis.Pool
-	maxLifeTime int64
+	config           *Config
+	values           *fasthttpsession.CCMap
+	redisPool        *redis.Pool
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	logger           fasthttpsession.Logger
 }
 
 // new redis provider
@@ -30,6 +40,7 @@ func NewProvider() *Provider {
 		config:    &Config{},
 		values:    fasthttpsession.NewDefaultCCMap(),
 		redisPool: &redis.Pool{},
+		logger:    fasthttpsession.NewNopLogger(),
 	}
 }
 
@@ -56,6 +67,9 @@ func (rp *Provider) Init(lifeTime int64, redisConfig fasthttpsession.ProviderCon
 	if rp.config.IdleTimeout <= 0 {
 		return errors.New("session redis provider init error, config IdleTimeout must be more than 0")
 	}
+	if rp.config.LockExpire <= 0 {
+		rp.config.LockExpire = defaultLockExpire
+	}
 	// init config serialize func
 	if rp.config.SerializeFunc == nil {
 		rp.config.SerializeFunc = encrypt.GobEncode
@@ -76,6 +90,16 @@ func (rp *Provider) Init(lifeTime int64, redisConfig fasthttpsession.ProviderCon
 	return nil
 }
 
+// set the absolute session lifetime(s), 0 means no limit
+func (rp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	rp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the logger of the failures which can not be returned
+func (rp *Provider) SetLogger(logger fasthttpsession.Logger) {
+	rp.logger = logger
+}
+
 // not need gc
 func (rp *Provider) NeedGC() bool {
 	return false
@@ -86,17 +110,28 @@ func (rp *Provider) GC() {}
 
 // read session store by session id
 func (rp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return rp.ReadStoreContext(context.Background(), sessionId)
+}
 
-	conn := rp.redisPool.Get()
+// read session store by session id with context
+func (rp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
+
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer conn.Close()
 
-	reply, err := redis.Bytes(conn.Do("GET", rp.getRedisSessionKey(sessionId)))
+	reply, err := redis.Bytes(rp.do(ctx, conn, "GET", rp.getRedisSessionKey(sessionId)))
 	if err != nil && err != redis.ErrNil {
 		return nil, err
 	}
 	if len(reply) == 0 {
-		conn.Do("SET", rp.getRedisSessionKey(sessionId), "", "EX", rp.maxLifeTime)
-		return NewRedisStore(sessionId), nil
+		_, err = rp.do(ctx, conn, "SET", rp.getRedisSessionKey(sessionId), "", "EX", rp.maxLifeTime)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(rp, sessionId), nil
 	}
 
 	data, err := rp.config.UnSerializeFunc(reply)
@@ -104,51 +139,329 @@ func (rp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 		return nil, err
 	}
 
-	return NewRedisStoreData(sessionId, data), nil
+	store := NewRedisStoreData(rp, sessionId, data)
+	store.SetSize(len(reply))
+	return store, nil
 }
 
 // regenerate session
 func (rp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return rp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
 
-	conn := rp.redisPool.Get()
+// regenerate session with context
+func (rp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer conn.Close()
 
-	existed, err := redis.Int(conn.Do("EXISTS", rp.getRedisSessionKey(oldSessionId)))
-	if err != nil || existed == 0 {
+	existed, err := redis.Int(rp.do(ctx, conn, "EXISTS", rp.getRedisSessionKey(oldSessionId)))
+	if err != nil {
+		return nil, err
+	}
+	if existed == 0 {
 		// false
-		conn.Do("SET", rp.getRedisSessionKey(sessionId), "", "EX", rp.maxLifeTime)
-		return NewRedisStore(sessionId), nil
+		_, err = rp.do(ctx, conn, "SET", rp.getRedisSessionKey(sessionId), "", "EX", rp.maxLifeTime)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisStore(rp, sessionId), nil
 	}
 	// true
-	conn.Do("RENAME", rp.getRedisSessionKey(oldSessionId), rp.getRedisSessionKey(sessionId))
-	conn.Do("EXPIRE", rp.getRedisSessionKey(sessionId), rp.maxLifeTime)
-
-	return rp.ReadStore(sessionId)
+	_, err = rp.do(ctx, conn, "RENAME", rp.getRedisSessionKey(oldSessionId), rp.getRedisSessionKey(sessionId))
+	if err != nil {
+		return nil, err
+	}
+	store, err := rp.ReadStoreContext(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	// keep the TTL and the absolute lifetime of the session, as Save
+	lifeTime := store.(*Store).Lifetime(rp.maxLifeTime, rp.absoluteLifeTime)
+	_, err = rp.do(ctx, conn, "EXPIRE", rp.getRedisSessionKey(sessionId), lifeTime)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
 }
 
 // destroy session by sessionId
 func (rp *Provider) Destroy(sessionId string) error {
-	conn := rp.redisPool.Get()
+	return rp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (rp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
-	existed, err := redis.Int(conn.Do("EXISTS", rp.getRedisSessionKey(sessionId)))
-	if err != nil || existed == 0 {
-		return nil
+	_, err = rp.do(ctx, conn, "DEL", rp.getRedisSessionKey(sessionId))
+	return err
+}
+
+// session exists by sessionId
+func (rp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	existed, err := redis.Int(rp.do(ctx, conn, "EXISTS", rp.getRedisSessionKey(sessionId)))
+	if err != nil {
+		return false, err
+	}
+	return existed > 0, nil
+}
+
+// lock session by sessionId, SET NX with a random token and the LockExpire expiry
+func (rp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, err
+	}
+	lockKey := rp.getRedisLockKey(sessionId)
+	lockToken := hex.EncodeToString(token)
+
+	err = fasthttpsession.WaitLock(ctx, func() (bool, error) {
+		conn, err := rp.redisPool.GetContext(ctx)
+		if err != nil {
+			return false, err
+		}
+		defer conn.Close()
+
+		_, err = redis.String(rp.do(ctx, conn, "SET", lockKey, lockToken, "NX", "EX", rp.config.LockExpire))
+		if err == redis.ErrNil {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		conn := rp.redisPool.Get()
+		defer conn.Close()
+
+		_, err := unlockScript.Do(conn, lockKey, lockToken)
+		return err
+	}, nil
+}
+
+// session ids of userID
+// the ids of expired sessions or sessions given to another user are removed from the index
+func (rp *Provider) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	userKey := rp.getRedisUserKey(userID)
+	members, err := redis.Strings(rp.do(ctx, conn, "SMEMBERS", userKey))
+	if err != nil {
+		return nil, err
+	}
+	sessionIds := make([]string, 0, len(members))
+	for _, sessionId := range members {
+		reply, err := redis.Bytes(rp.do(ctx, conn, "GET", rp.getRedisSessionKey(sessionId)))
+		if err != nil && err != redis.ErrNil {
+			return nil, err
+		}
+		if rp.getDataUserID(reply) != userID {
+			_, err = rp.do(ctx, conn, "SREM", userKey, sessionId)
+			if err != nil {
+				rp.logger.Warn("session user index cleanup error", "provider", ProviderName, "op", "user_sessions", "sessionId", sessionId, "error", err)
+			}
+			continue
+		}
+		sessionIds = append(sessionIds, sessionId)
+	}
+	return sessionIds, nil
+}
+
+// destroy all the sessions of userID
+func (rp *Provider) DestroyUserSessions(ctx context.Context, userID string) error {
+	sessionIds, err := rp.UserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, sessionId := range sessionIds {
+		_, err = rp.do(ctx, conn, "DEL", rp.getRedisSessionKey(sessionId))
+		if err != nil {
+			return err
+		}
+		_, err = rp.do(ctx, conn, "SREM", rp.getRedisUserKey(userID), sessionId)
+		if err != nil {
+			rp.logger.Warn("session user index cleanup error", "provider", ProviderName, "op", "destroy_user_sessions", "sessionId", sessionId, "error", err)
+		}
 	}
-	conn.Do("DEL", rp.getRedisSessionKey(sessionId))
 	return nil
 }
 
+// scan the sessions with SCAN, the cursor is the redis cursor
+func (rp *Provider) Scan(ctx context.Context, cursor string, limit int) ([]fasthttpsession.SessionInfo, string, error) {
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close()
+
+	if cursor == "" {
+		cursor = "0"
+	}
+	values, err := redis.Values(rp.do(ctx, conn, "SCAN", cursor, "MATCH", rp.config.KeyPrefix+":*", "COUNT", limit))
+	if err != nil {
+		return nil, "", err
+	}
+	var keys []string
+	_, err = redis.Scan(values, &cursor, &keys)
+	if err != nil {
+		return nil, "", err
+	}
+	if cursor == "0" {
+		cursor = ""
+	}
+
+	infos := make([]fasthttpsession.SessionInfo, 0, len(keys))
+	for _, key := range keys {
+		info, _, err := rp.peek(ctx, conn, strings.TrimPrefix(key, rp.config.KeyPrefix+":"))
+		if err == fasthttpsession.ErrSessionNotFound {
+			// expired since SCAN
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		infos = append(infos, info)
+	}
+	return infos, cursor, nil
+}
+
+// peek the session data, GET does not refresh the key expire
+func (rp *Provider) Peek(ctx context.Context, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	conn, err := rp.redisPool.GetContext(ctx)
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	defer conn.Close()
+
+	return rp.peek(ctx, conn, sessionId)
+}
+
 // session values count
 func (rp *Provider) Count() int {
 	conn := rp.redisPool.Get()
 	defer conn.Close()
 
-	replyMap, err := redis.Strings(conn.Do("KEYS", rp.config.KeyPrefix+":*"))
+	// SCAN does not block the redis server like KEYS on a large keyspace
+	count := 0
+	cursor := "0"
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", rp.config.KeyPrefix+":*", "COUNT", 1000))
+		if err != nil {
+			rp.logger.Error("session count error", "provider", ProviderName, "op", "count", "error", err)
+			return 0
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			rp.logger.Error("session count error", "provider", ProviderName, "op", "count", "error", err)
+			return 0
+		}
+		count += len(keys)
+		if cursor == "0" {
+			return count
+		}
+	}
+}
+
+// close the redis conn pool
+func (rp *Provider) Close() error {
+	return rp.redisPool.Close()
+}
+
+// get the version of the serialized session data, 0 for an empty session
+func (rp *Provider) getDataVersion(reply []byte) (int64, error) {
+	if len(reply) == 0 {
+		return 0, nil
+	}
+	data, err := rp.config.UnSerializeFunc(reply)
 	if err != nil {
-		return 0
+		return 0, err
 	}
-	return len(replyMap)
+	store := &fasthttpsession.Store{}
+	store.Init("", data)
+	return store.Version(), nil
+}
+
+// get the user id of the serialized session data, empty if there is none
+func (rp *Provider) getDataUserID(reply []byte) string {
+	if len(reply) == 0 {
+		return ""
+	}
+	data, err := rp.config.UnSerializeFunc(reply)
+	if err != nil {
+		return ""
+	}
+	store := &fasthttpsession.Store{}
+	store.Init("", data)
+	return store.UserID()
+}
+
+// get the session info and data of sessionId
+// the key expire is set to the session lifetime on every save, the last active time is derived from it
+func (rp *Provider) peek(ctx context.Context, conn redis.Conn, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	key := rp.getRedisSessionKey(sessionId)
+	reply, err := redis.Bytes(rp.do(ctx, conn, "GET", key))
+	if err == redis.ErrNil {
+		return fasthttpsession.SessionInfo{}, nil, fasthttpsession.ErrSessionNotFound
+	}
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	ttl, err := redis.Int64(rp.do(ctx, conn, "TTL", key))
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+
+	data := map[string]interface{}{}
+	if len(reply) > 0 {
+		data, err = rp.config.UnSerializeFunc(reply)
+		if err != nil {
+			return fasthttpsession.SessionInfo{}, nil, err
+		}
+	}
+	store := &fasthttpsession.Store{}
+	store.Init(sessionId, data)
+	info := store.Info()
+	info.Size = len(reply)
+	if ttl > 0 {
+		info.LastActiveAt = time.Now().Add(time.Duration(ttl-store.Lifetime(rp.maxLifeTime, rp.absoluteLifeTime)) * time.Second)
+	}
+	return info, store.GetAll(), nil
+}
+
+// add sessionId to the user index set of userID for lifeTime(s)
+func (rp *Provider) indexUser(ctx context.Context, conn redis.Conn, userID string, sessionId string, lifeTime int64) error {
+	if userID == "" {
+		return nil
+	}
+	_, err := indexUserScript.DoContext(ctx, conn, rp.getRedisUserKey(userID), sessionId, lifeTime)
+	return err
 }
 
 // get redis session key, prefix:sessionId
@@ -156,7 +469,25 @@ func (rp *Provider) getRedisSessionKey(sessionId string) string {
 	return rp.config.KeyPrefix + ":" + sessionId
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, provider)
+// get redis session lock key, prefix_lock:sessionId, not counted as a session
+func (rp *Provider) getRedisLockKey(sessionId string) string {
+	return rp.config.KeyPrefix + "_lock:" + sessionId
+}
+
+// get redis user index key, prefix_user:userID, the set of the user session ids
+func (rp *Provider) getRedisUserKey(userID string) string {
+	return rp.config.KeyPrefix + "_user:" + userID
+}
+
+// do the redis command in a span of the tracer carried by ctx
+func (rp *Provider) do(ctx context.Context, conn redis.Conn, commandName string, args ...interface{}) (interface{}, error) {
+	ctx, span := fasthttpsession.StartSpan(ctx, "redis "+commandName,
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBSystem, "redis"),
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBOperation, commandName))
+	reply, err := redis.DoContext(conn, ctx, commandName, args...)
+	if b, ok := reply.([]byte); ok {
+		span.SetAttributes(fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, len(b)))
+	}
+	fasthttpsession.EndSpan(span, err)
+	return reply, err
 }
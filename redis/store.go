@@ -1,40 +1,105 @@
 package redis
 
 import (
+	"context"
+
 	"github.com/brunohass/fasthttpsession"
+	"github.com/gomodule/redigo/redis"
 	"github.com/valyala/fasthttp"
 )
 
 // session redis store
 
 // new default redis store
-func NewRedisStore(sessionId string) *Store {
-	redisStore := &Store{}
+func NewRedisStore(provider *Provider, sessionId string) *Store {
+	redisStore := &Store{provider: provider}
 	redisStore.Init(sessionId, make(map[string]interface{}))
 	return redisStore
 }
 
 // new redis store data
-func NewRedisStoreData(sessionId string, data map[string]interface{}) *Store {
-	redisStore := &Store{}
+func NewRedisStoreData(provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	redisStore := &Store{provider: provider}
 	redisStore.Init(sessionId, data)
 	return redisStore
 }
 
 type Store struct {
 	fasthttpsession.Store
+	provider *Provider
 }
 
 // save store
 func (rs *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return rs.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
 
-	b, err := provider.config.SerializeFunc(rs.GetAll())
+// save store with context
+// an unchanged store only refreshes the key expire
+// changed data is written in a WATCH/MULTI transaction, fasthttpsession.ErrConflict
+// is returned if the session version moved since it was read
+// a session with a user id is added to the user index
+func (rs *Store) SaveContext(ctx context.Context) error {
+
+	conn, err := rs.provider.redisPool.GetContext(ctx)
 	if err != nil {
 		return err
 	}
-	conn := provider.redisPool.Get()
 	defer conn.Close()
-	conn.Do("SETEX", provider.getRedisSessionKey(rs.GetSessionId()), provider.maxLifeTime, string(b))
 
-	return nil
+	key := rs.provider.getRedisSessionKey(rs.GetSessionId())
+	lifeTime := rs.Lifetime(rs.provider.maxLifeTime, rs.provider.absoluteLifeTime)
+	if !rs.IsDirty() {
+		_, err = rs.provider.do(ctx, conn, "EXPIRE", key, lifeTime)
+		if err != nil {
+			return err
+		}
+		return rs.provider.indexUser(ctx, conn, rs.UserID(), rs.GetSessionId(), lifeTime)
+	}
+
+	_, err = rs.provider.do(ctx, conn, "WATCH", key)
+	if err != nil {
+		return err
+	}
+	reply, err := redis.Bytes(rs.provider.do(ctx, conn, "GET", key))
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+	version, err := rs.provider.getDataVersion(reply)
+	if err != nil {
+		return err
+	}
+	if version != rs.Version() {
+		rs.provider.do(ctx, conn, "UNWATCH")
+		return fasthttpsession.ErrConflict
+	}
+
+	b, err := rs.provider.config.SerializeFunc(rs.Export())
+	if err != nil {
+		rs.provider.do(ctx, conn, "UNWATCH")
+		return err
+	}
+	spanCtx, span := fasthttpsession.StartSpan(ctx, "redis SETEX",
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBSystem, "redis"),
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBOperation, "SETEX"),
+		fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, len(b)))
+	err = conn.Send("MULTI")
+	if err == nil {
+		err = conn.Send("SETEX", key, lifeTime, string(b))
+	}
+	if err == nil {
+		_, err = redis.Values(redis.DoContext(conn, spanCtx, "EXEC"))
+	}
+	fasthttpsession.EndSpan(span, err)
+	if err == redis.ErrNil {
+		// the key changed after WATCH
+		return fasthttpsession.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	rs.MarkSaved()
+	rs.SetSize(len(b))
+
+	return rs.provider.indexUser(ctx, conn, rs.UserID(), rs.GetSessionId(), lifeTime)
 }
@@ -0,0 +1,71 @@
+package fasthttpsession
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrScanNotSupported = errors.New("session scan error, provider does not implement ScanProvider")
+	ErrSessionNotFound  = errors.New("session not found")
+)
+
+// default Scan limit
+const defaultScanLimit = 100
+
+// SessionInfo is the meta data of a session returned by Scan and Peek
+type SessionInfo struct {
+	SessionId string
+
+	// session create time
+	CreatedAt time.Time
+
+	// last save time, zero if the provider does not keep it
+	LastActiveAt time.Time
+
+	// session idle lifetime, 0 means the provider lifetime
+	TTL time.Duration
+
+	// user id of SessionStore.SetUserID
+	UserID string
+
+	// size of the stored session data in bytes, 0 if the provider does not serialize it
+	Size int
+}
+
+// Scan browses the sessions of the provider, e.g. for admin tooling,
+// at most about limit sessions after cursor are returned, "" starts the scan,
+// the returned cursor is "" at the end of the scan.
+// limit <= 0 means 100.
+func (s *Session) Scan(ctx context.Context, cursor string, limit int) ([]SessionInfo, string, error) {
+	provider, err := s.scanProvider()
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		limit = defaultScanLimit
+	}
+	return provider.Scan(ctx, cursor, limit)
+}
+
+// Peek reads the session data without creating the session or refreshing its lifetime,
+// ErrSessionNotFound if the session does not exist
+func (s *Session) Peek(ctx context.Context, sessionId string) (SessionInfo, map[string]interface{}, error) {
+	provider, err := s.scanProvider()
+	if err != nil {
+		return SessionInfo{}, nil, err
+	}
+	return provider.Peek(ctx, sessionId)
+}
+
+func (s *Session) scanProvider() (ScanProvider, error) {
+	if s.provider == nil {
+		return nil, errors.New("session scan error, not set provider")
+	}
+	provider, ok := s.provider.(ScanProvider)
+	if !ok {
+		return nil, ErrScanNotSupported
+	}
+	return provider, nil
+}
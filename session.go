@@ -1,9 +1,12 @@
 package fasthttpsession
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	cmap "github.com/orcaman/concurrent-map"
@@ -16,22 +19,33 @@ var version = "v0.0.1"
 type Session struct {
 	provider Provider
 	config   *Config
-	cookie   *Cookie
 	ccmap    cmap.ConcurrentMap
-}
 
-var providers = make(map[string]Provider)
+	// provider config name, the provider label of the metrics
+	providerName string
 
-// register session provider
-func Register(providerName string, provider Provider) {
-	if providers[providerName] != nil {
-		panic("session register error, provider " + providerName + " already registered!")
-	}
-	if provider == nil {
-		panic("session register error, provider " + providerName + " is nil!")
-	}
+	gcProcess *gcProcess
+
+	// request user value key of the middleware session state
+	userValueKey string
+
+	// request user value key of the session lock held by the request
+	lockUserValueKey string
+
+	// request user value key of a fingerprint mismatch, FingerprintFlag
+	fingerprintUserValueKey string
+
+	// session id extractors and injectors of the cookie, query and header options,
+	// used without Config.Extractors and Config.Injectors
+	defaultExtractors []Extractor
+	defaultInjectors  []Injector
+}
 
-	providers[providerName] = provider
+// session gc process, stopped by Session.Close
+type gcProcess struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // return new Session
@@ -49,12 +63,27 @@ func NewSession(cfg *Config) *Session {
 	if cfg.SessionIdGeneratorFunc == nil {
 		cfg.SessionIdGeneratorFunc = cfg.defaultSessionIdGenerator
 	}
+	if cfg.LockTimeout == 0 {
+		cfg.LockTimeout = defaultLockTimeout
+	}
+	if cfg.UpdateRetries == 0 {
+		cfg.UpdateRetries = defaultUpdateRetries
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = NewStdLogger(nil)
+	}
+	if len(cfg.SigningKeys) > 0 {
+		cfg.signer = NewSigner(cfg.SigningKeys...)
+	}
 
 	session := &Session{
 		config: cfg,
-		cookie: NewCookie(),
 		ccmap:  cmap.New(),
 	}
+	session.userValueKey = fmt.Sprintf("fasthttpsession.%p", session)
+	session.lockUserValueKey = session.userValueKey + ".lock"
+	session.fingerprintUserValueKey = session.userValueKey + ".fingerprint"
+	session.initDefaultChains()
 
 	return session
 }
@@ -63,6 +92,7 @@ func NewSession(cfg *Config) *Session {
 func (s *Session) ChangeCookieName(cookieName string) {
 	if cookieName != "" {
 		s.config.CookieName = cookieName
+		s.initDefaultChains()
 	}
 }
 
@@ -72,40 +102,106 @@ func (s *Session) ChangeNeedStoreInMap(controlFlg bool) {
 }
 
 // set session provider and provider config
-func (s *Session) SetProvider(providerName string, providerConfig ProviderConfig) error {
-	provider, ok := providers[providerName]
-	if !ok {
-		return errors.New("session set provider error, " + providerName + " not registered!")
+// every session owns its provider instance, e.g. SetProvider(redis.NewProvider(), &redis.Config{...})
+func (s *Session) SetProvider(provider Provider, providerConfig ProviderConfig) error {
+	if provider == nil {
+		return errors.New("session set provider error, provider is nil")
+	}
+	if absoluteLifetimeProvider, ok := provider.(AbsoluteLifetimeProvider); ok {
+		absoluteLifetimeProvider.SetAbsoluteLifetime(s.config.SessionAbsoluteLifetime)
+	}
+	// the providers report the expired sessions one by one, only when they are consumed
+	if expireNotifyProvider, ok := provider.(ExpireNotifyProvider); ok && (s.config.Hooks.OnExpire != nil || s.config.Metrics != nil) {
+		expireNotifyProvider.SetExpireFunc(s.onExpire)
+	}
+	if loggerProvider, ok := provider.(LoggerProvider); ok {
+		loggerProvider.SetLogger(s.config.logger())
+	}
+	if err := checkSigningKeys(s.config.SigningKeys); err != nil {
+		return fmt.Errorf("session set provider error, %w", err)
+	}
+	if _, ok := provider.(ExistsProvider); s.config.StrictSessionId && !ok {
+		return errors.New("session set provider error, StrictSessionId requires an ExistsProvider")
 	}
 	err := provider.Init(s.config.SessionLifetime, providerConfig)
 	if err != nil {
 		return err
 	}
 	s.provider = provider
+	s.providerName = providerConfig.Name()
+	if s.config.Metrics != nil {
+		s.config.Metrics.SetCountFunc(s.metricsLabel(), provider.Count)
+	}
 
 	// start gc
 	if s.provider.NeedGC() {
+		process := &gcProcess{
+			stop: make(chan struct{}),
+			done: make(chan struct{}),
+		}
+		s.gcProcess = process
 		go func() {
-			defer func() {
-				e := recover()
-				if e != nil {
-					panic(errors.New(fmt.Sprintf("session gc crash, %v", e)))
-				}
-			}()
-			s.gc()
+			defer close(process.done)
+			s.gc(process.stop)
 		}()
 	}
 	return nil
 }
 
-// start session gc process.
-func (s *Session) gc() {
+// start session gc process, until stop is closed.
+func (s *Session) gc(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(s.config.GCLifetime) * time.Second)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-time.After(time.Duration(s.config.GCLifetime) * time.Second):
-			s.provider.GC()
+		case <-ticker.C:
+			s.runGC()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// run the provider gc once, a panic is logged and the gc runs again on the next tick
+func (s *Session) runGC() {
+	start := time.Now()
+	_, span := s.startSpan(s.parentContext(nil), "fasthttpsession.GC")
+	defer func() {
+		if e := recover(); e != nil {
+			s.config.logger().Error("session gc crash", "provider", s.providerName, "op", "gc", "panic", e, "stack", string(debug.Stack()))
+			EndSpan(span, fmt.Errorf("session %s provider gc crash, %v", s.providerName, e))
+			return
+		}
+		span.End()
+		s.observeGC(start)
+	}()
+	s.provider.GC()
+}
+
+// close session
+// 1. stop the gc process and wait for the running gc to finish
+// 2. release the provider backend resources (redis pool, sql db, ...)
+// ctx bounds the wait for the gc process
+func (s *Session) Close(ctx context.Context) error {
+	if s.provider == nil {
+		return nil
+	}
+
+	if process := s.gcProcess; process != nil {
+		process.stopOnce.Do(func() {
+			close(process.stop)
+		})
+		select {
+		case <-process.done:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
+
+	if provider, ok := s.provider.(ClosableProvider); ok {
+		return provider.Close()
+	}
+	return nil
 }
 
 func getCtxPointer(ctx *fasthttp.RequestCtx) string {
@@ -144,78 +240,154 @@ func (s *Session) RemoveSessionStoreWithCtx(ctx *fasthttp.RequestCtx) bool {
 
 // session start
 // 1. get sessionId from fasthttp ctx
-// 2. if sessionId is empty, generator sessionId and set response Set-Cookie
-// 3. return session provider store
+// 2. if sessionId is empty or unknown with Config.StrictSessionId, generator sessionId and set response Set-Cookie
+// 3. lock the session if Config.LockSession is set, see StartLocked
+// 4. if the session reached the absolute lifetime, destroy it and start a new session
+// 5. if the client fingerprint does not match the session, apply Config.FingerprintPolicy
+// 6. return session provider store
 func (s *Session) Start(ctx *fasthttp.RequestCtx) (sessionStore SessionStore, err error) {
+	return s.start(ctx, s.config.LockSession)
+}
+
+func (s *Session) start(ctx *fasthttp.RequestCtx, lock bool) (sessionStore SessionStore, err error) {
 	if s.provider == nil {
 		return sessionStore, errors.New("session start error, not set provider")
 	}
 
-	sessionId := s.GetSessionId(ctx)
+	sessionId := s.clientSessionId(ctx)
+	created := false
+	if sessionId != "" && s.config.StrictSessionId {
+		// discard the session id unknown by the provider
+		exists, err := s.existsStore(ctx, sessionId)
+		if err != nil {
+			return sessionStore, errors.New(fmt.Sprintf("Error when check session : %s", err.Error()))
+		}
+		if !exists {
+			sessionId = ""
+		}
+	}
 	if sessionId == "" {
 		// new generator session id
 		sessionId = s.config.SessionIdGenerator()
 		if sessionId == "" {
 			return sessionStore, errors.New("session generator sessionId is empty")
 		}
+		created = true
+	} else if lock {
+		// a new session id is not known by other requests yet
+		err = s.lock(ctx, sessionId)
+		if err != nil {
+			return sessionStore, errors.New(fmt.Sprintf("Error when lock session : %s", err.Error()))
+		}
 	}
 
+	c, cancel := s.providerContext(ctx)
+	defer cancel()
+
 	// read provider session store
-	sessionStore, err = s.provider.ReadStore(sessionId)
+	sessionStore, err = s.readStore(c, sessionId)
 	if err != nil {
 		return sessionStore, errors.New(fmt.Sprintf("Error when read session data : %s", err.Error()))
 	}
 
-	// encode cookie value
-	encodeCookieValue := s.config.Encode(sessionId)
-
-	// set response cookie
-	s.cookie.Set(ctx,
-		s.config.CookieName,
-		encodeCookieValue,
-		s.config.Domain,
-		s.config.Expires,
-		s.config.Secure,
-		s.config.SameSite,
-		s.config.HTTPOnly)
+	// session reached the absolute lifetime, replace it by a new session
+	if s.isAbsoluteExpired(sessionStore) {
+		err = s.destroyStore(c, sessionId)
+		if err != nil {
+			s.logError("session destroy error", "expire", sessionId, err)
+		}
+		s.onExpire(sessionId)
+		sessionId = s.config.SessionIdGenerator()
+		if sessionId == "" {
+			return sessionStore, errors.New("session generator sessionId is empty")
+		}
+		sessionStore, err = s.readStore(c, sessionId)
+		if err != nil {
+			return sessionStore, errors.New(fmt.Sprintf("Error when read session data : %s", err.Error()))
+		}
+		created = true
+	}
+	if created {
+		s.onCreate(ctx, sessionStore)
+	} else {
+		s.onLoad(ctx, sessionStore)
+	}
 
-	if s.config.SessionIdInHttpHeader {
-		ctx.Request.Header.Set(s.config.SessionNameInHttpHeader, sessionId)
-		ctx.Response.Header.Set(s.config.SessionNameInHttpHeader, sessionId)
+	// session used by another client than the one bound to it
+	if s.config.FingerprintFunc != nil {
+		sessionStore, err = s.checkFingerprint(ctx, c, sessionStore)
+		if err != nil {
+			return sessionStore, errors.New(fmt.Sprintf("Error when check session fingerprint : %s", err.Error()))
+		}
+		sessionId = sessionStore.GetSessionId()
 	}
 
+	// set response cookie
+	s.inject(ctx, sessionId, sessionStore)
+
 	if s.config.NeedStoreInMap {
 		s.SetSessionStoreWithCtx(ctx, sessionStore)
 	}
+	s.trackStore(ctx, sessionStore)
 
 	return sessionStore, nil
 }
 
-// get session id
+// get session id, empty if there is none or it can not be decoded
+// the session id is read by the Config.Extractors, by default:
 // 1. get session id by reading from cookie
 // 2. get session id from query
 // 3. get session id from http headers
 func (s *Session) GetSessionId(ctx *fasthttp.RequestCtx) string {
-	cookieByte := ctx.Request.Header.Cookie(s.config.CookieName)
-	if len(cookieByte) > 0 {
-		return s.config.Decode(string(cookieByte))
-	}
+	sessionId, _ := s.ParseSessionId(ctx)
+	return sessionId
+}
 
-	if s.config.SessionIdInURLQuery {
-		cookieFormValue := ctx.FormValue(s.config.SessionNameInUrlQuery)
-		if len(cookieFormValue) > 0 {
-			return s.config.Decode(string(cookieFormValue))
+// parse the session id like GetSessionId, the error tells why the session id
+// sent by the client was rejected, e.g. ErrSessionIdSignature
+func (s *Session) ParseSessionId(ctx *fasthttp.RequestCtx) (string, error) {
+	for _, extractor := range s.extractors() {
+		value := extractor.Extract(ctx)
+		if value != "" {
+			return s.config.DecodeSessionId(value)
 		}
 	}
 
-	if s.config.SessionIdInHttpHeader {
-		cookieHeader := ctx.Request.Header.Peek(s.config.SessionNameInHttpHeader)
-		if len(cookieHeader) > 0 {
-			return s.config.Decode(string(cookieHeader))
-		}
+	return "", nil
+}
+
+// session id extractors, Config.Extractors or the ones of the cookie, query and header options
+func (s *Session) extractors() []Extractor {
+	if s.config.Extractors != nil {
+		return s.config.Extractors
 	}
+	return s.defaultExtractors
+}
 
-	return ""
+// session id injectors, Config.Injectors or the ones of the cookie and header options
+func (s *Session) injectors() []Injector {
+	if s.config.Injectors != nil {
+		return s.config.Injectors
+	}
+	return s.defaultInjectors
+}
+
+// build the extractors and injectors of the cookie, query and header options
+func (s *Session) initDefaultChains() {
+	cookieExtractor := NewCookieExtractor(s.config.CookieName, s.cookieOptions(0))
+
+	s.defaultExtractors = []Extractor{cookieExtractor}
+	if s.config.SessionIdInURLQuery {
+		s.defaultExtractors = append(s.defaultExtractors,
+			NewQueryExtractor(s.config.SessionNameInUrlQuery),
+			NewFormExtractor(s.config.SessionNameInUrlQuery))
+	}
+	s.defaultInjectors = []Injector{cookieExtractor}
+	if s.config.SessionIdInHttpHeader {
+		headerExtractor := NewHeaderExtractor(s.config.SessionNameInHttpHeader)
+		s.defaultExtractors = append(s.defaultExtractors, headerExtractor)
+		s.defaultInjectors = append(s.defaultInjectors, headerExtractor)
+	}
 }
 
 // regenerate a session id for this SessionStore
@@ -230,40 +402,33 @@ func (s *Session) Regenerate(ctx *fasthttp.RequestCtx) (sessionStore SessionStor
 	if sessionId == "" {
 		return sessionStore, errors.New("session generator sessionId is empty")
 	}
-	// encode cookie value
-	encodeCookieValue := s.config.Encode(sessionId)
+	c, cancel := s.providerContext(ctx)
+	defer cancel()
 
 	// regenerate provider session store
-	oldSessionId := s.GetSessionId(ctx)
+	oldSessionId := s.clientSessionId(ctx)
 	if oldSessionId != "" {
-		sessionStore, err = s.provider.Regenerate(oldSessionId, sessionId)
+		sessionStore, err = s.regenerateStore(c, oldSessionId, sessionId)
 	} else {
-		sessionStore, err = s.provider.ReadStore(sessionId)
+		sessionStore, err = s.readStore(c, sessionId)
 	}
 
 	if err != nil {
 		return sessionStore, errors.New(fmt.Sprintf("Error when read session data : %s", err.Error()))
 	}
+	if oldSessionId != "" {
+		s.onRegenerate(ctx, oldSessionId, sessionId)
+	} else {
+		s.onCreate(ctx, sessionStore)
+	}
 
 	// reset response cookie
-	s.cookie.Set(ctx,
-		s.config.CookieName,
-		encodeCookieValue,
-		s.config.Domain,
-		s.config.Expires,
-		s.config.Secure,
-		s.config.SameSite,
-		s.config.HTTPOnly)
-
-	// reset http header
-	if s.config.SessionIdInHttpHeader {
-		ctx.Request.Header.Set(s.config.SessionNameInHttpHeader, sessionId)
-		ctx.Response.Header.Set(s.config.SessionNameInHttpHeader, sessionId)
-	}
+	s.inject(ctx, sessionId, sessionStore)
 
 	if s.config.NeedStoreInMap {
 		s.SetSessionStoreWithCtx(ctx, sessionStore)
 	}
+	s.trackStore(ctx, sessionStore)
 
 	return sessionStore, nil
 }
@@ -273,23 +438,207 @@ func (s *Session) Destroy(ctx *fasthttp.RequestCtx) {
 	if s.config.NeedStoreInMap {
 		defer s.RemoveSessionStoreWithCtx(ctx)
 	}
+	s.untrackStore(ctx)
 
-	// delete header if sessionId in http Header
-	if s.config.SessionIdInHttpHeader {
-		ctx.Request.Header.Del(s.config.SessionNameInHttpHeader)
-		ctx.Response.Header.Del(s.config.SessionNameInHttpHeader)
+	// a session id which can not be decoded was never read
+	sessionId := s.clientSessionId(ctx)
+	if sessionId != "" {
+		c, cancel := s.providerContext(ctx)
+		defer cancel()
+		err := s.destroyStore(c, sessionId)
+		if err != nil {
+			s.logError("session destroy error", "destroy", sessionId, err)
+		}
+		s.onDestroy(ctx, sessionId)
+	}
+
+	// delete cookie and header, with the attributes they were set with
+	for _, injector := range s.injectors() {
+		injector.Remove(ctx)
 	}
+}
+
+// session is older than the absolute session lifetime
+func (s *Session) isAbsoluteExpired(sessionStore SessionStore) bool {
+	if s.config.SessionAbsoluteLifetime <= 0 {
+		return false
+	}
+	expiredAt := sessionStore.CreatedAt().Add(time.Duration(s.config.SessionAbsoluteLifetime) * time.Second)
+	return !time.Now().Before(expiredAt)
+}
 
-	cookieValue := s.cookie.Get(ctx, s.config.CookieName)
-	if cookieValue == "" {
+// write the session id with the injectors, a session with its own TTL gets a matching cookie expiry
+func (s *Session) inject(ctx *fasthttp.RequestCtx, sessionId string, sessionStore SessionStore) {
+	// encode cookie value, an empty value would drop the session id of the client
+	encodeCookieValue, err := s.config.EncodeSessionId(sessionId)
+	if err != nil {
+		s.logError("session id encode error", "inject", sessionId, err)
 		return
 	}
 
-	sessionId := s.config.Decode(cookieValue)
-	s.provider.Destroy(sessionId)
+	expires := s.config.Expires
+	if ttl := sessionStore.TTL(); ttl > 0 {
+		expires = ttl
+	}
+
+	for _, injector := range s.injectors() {
+		injector.Inject(ctx, encodeCookieValue, expires)
+	}
+}
+
+// session cookie options of the config
+func (s *Session) cookieOptions(expires time.Duration) CookieOptions {
+	return CookieOptions{
+		Domain:         s.config.Domain,
+		Path:           s.config.Path,
+		Expires:        expires,
+		MaxAge:         s.config.MaxAge,
+		Secure:         s.config.Secure,
+		AlwaysSecure:   s.config.AlwaysSecure,
+		TrustedProxies: s.config.TrustedProxies,
+		SameSite:       s.config.SameSite,
+		HTTPOnly:       s.config.HTTPOnly,
+		Partitioned:    s.config.Partitioned,
+	}
+}
+
+// save session store, the provider call is bounded like in Start
+// the cookie is set again, its expiry follows a TTL changed by the handler
+func (s *Session) Save(ctx *fasthttp.RequestCtx, sessionStore SessionStore) error {
+	s.inject(ctx, sessionStore.GetSessionId(), sessionStore)
+	c, cancel := s.providerContext(ctx)
+	defer cancel()
+	c, span := s.startSpan(c, "fasthttpsession.Save")
+	start := time.Now()
+	var err error
+	if store, ok := sessionStore.(ContextSessionStore); ok {
+		err = store.SaveContext(c)
+	} else {
+		err = sessionStore.Save(ctx)
+	}
+	if err != nil {
+		EndSpan(span, err)
+		return err
+	}
+	span.SetAttributes(IntAttribute(AttributeSize, sessionStore.Size()))
+	span.End()
+	s.observeSave(start, sessionStore)
+	s.onSave(ctx, sessionStore)
+	return nil
+}
 
-	// delete cookie by cookieName
-	s.cookie.Delete(ctx, s.config.CookieName)
+// Update starts the session, applies fn and saves the session.
+// The session is read again and fn applied again when the save fails with ErrConflict,
+// at most Config.UpdateRetries times, so fn must only depend on the store it gets.
+// It is the optimistic alternative to StartLocked.
+func (s *Session) Update(ctx *fasthttp.RequestCtx, fn func(SessionStore) error) error {
+	for retry := 0; ; retry++ {
+		sessionStore, err := s.Start(ctx)
+		if err != nil {
+			return err
+		}
+		err = fn(sessionStore)
+		if err != nil {
+			return err
+		}
+		err = s.Save(ctx, sessionStore)
+		if !errors.Is(err, ErrConflict) || retry >= s.config.UpdateRetries {
+			return err
+		}
+	}
+}
+
+// provider call context, carries the request ctx and is bounded by Config.ProviderTimeout
+func (s *Session) providerContext(ctx *fasthttp.RequestCtx) (context.Context, context.CancelFunc) {
+	c := ContextWithRequestCtx(s.parentContext(ctx), ctx)
+	if s.config.ProviderTimeout > 0 {
+		return context.WithTimeout(c, s.config.ProviderTimeout)
+	}
+	return context.WithCancel(c)
+}
+
+// parent context of the provider calls, Config.ParentContextFunc with the Config.Tracer
+// ctx is nil outside a request
+func (s *Session) parentContext(ctx *fasthttp.RequestCtx) context.Context {
+	c := context.Background()
+	if s.config.ParentContextFunc != nil && ctx != nil {
+		if parent := s.config.ParentContextFunc(ctx); parent != nil {
+			c = parent
+		}
+	}
+	if s.config.Tracer != nil {
+		c = ContextWithTracer(c, s.config.Tracer)
+	}
+	return c
+}
+
+// start a span of a provider call, see Config.Tracer
+func (s *Session) startSpan(c context.Context, spanName string) (context.Context, Span) {
+	return StartSpan(c, spanName, StringAttribute(AttributeProvider, s.providerName))
+}
+
+func (s *Session) readStore(c context.Context, sessionId string) (sessionStore SessionStore, err error) {
+	c, span := s.startSpan(c, "fasthttpsession.ReadStore")
+	start := time.Now()
+	if provider, ok := s.provider.(ContextProvider); ok {
+		sessionStore, err = provider.ReadStoreContext(c, sessionId)
+	} else {
+		sessionStore, err = s.provider.ReadStore(sessionId)
+	}
+	if err == nil {
+		span.SetAttributes(IntAttribute(AttributeSize, sessionStore.Size()))
+		s.observeRead(start, sessionStore)
+	}
+	EndSpan(span, err)
+	return sessionStore, err
+}
+
+func (s *Session) regenerateStore(c context.Context, oldSessionId string, sessionId string) (sessionStore SessionStore, err error) {
+	c, span := s.startSpan(c, "fasthttpsession.Regenerate")
+	if provider, ok := s.provider.(ContextProvider); ok {
+		sessionStore, err = provider.RegenerateContext(c, oldSessionId, sessionId)
+	} else {
+		sessionStore, err = s.provider.Regenerate(oldSessionId, sessionId)
+	}
+	if err == nil {
+		span.SetAttributes(IntAttribute(AttributeSize, sessionStore.Size()))
+	}
+	EndSpan(span, err)
+	return sessionStore, err
+}
+
+// the session id sent by the client, "" if the provider can not store it, see SessionIdCheckProvider
+func (s *Session) clientSessionId(ctx *fasthttp.RequestCtx) string {
+	sessionId := s.GetSessionId(ctx)
+	if provider, ok := s.provider.(SessionIdCheckProvider); ok && sessionId != "" && !provider.ValidSessionId(sessionId) {
+		return ""
+	}
+	return sessionId
+}
+
+// the provider knows the session id, Config.StrictSessionId
+func (s *Session) existsStore(ctx *fasthttp.RequestCtx, sessionId string) (bool, error) {
+	provider, ok := s.provider.(ExistsProvider)
+	if !ok {
+		return false, errors.New("session provider does not support StrictSessionId")
+	}
+	c, cancel := s.providerContext(ctx)
+	defer cancel()
+	c, span := s.startSpan(c, "fasthttpsession.Exists")
+	exists, err := provider.Exists(c, sessionId)
+	EndSpan(span, err)
+	return exists, err
+}
+
+func (s *Session) destroyStore(c context.Context, sessionId string) (err error) {
+	c, span := s.startSpan(c, "fasthttpsession.Destroy")
+	if provider, ok := s.provider.(ContextProvider); ok {
+		err = provider.DestroyContext(c, sessionId)
+	} else {
+		err = s.provider.Destroy(sessionId)
+	}
+	EndSpan(span, err)
+	return err
 }
 
 func Version() string {
@@ -0,0 +1,214 @@
+package fasthttpsession_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brunohass/fasthttpsession"
+	"github.com/brunohass/fasthttpsession/memory"
+	"github.com/valyala/fasthttp"
+)
+
+func TestSetProviderSigningKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		wantErr bool
+	}{
+		{"no keys", nil, false},
+		{"valid keys", [][]byte{bytes.Repeat([]byte("k"), 32), bytes.Repeat([]byte("o"), 64)}, false},
+		{"empty key", [][]byte{{}}, true},
+		{"short previous key", [][]byte{bytes.Repeat([]byte("k"), 32), []byte("secret")}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fasthttpsession.NewDefaultConfig()
+			config.SigningKeys = test.keys
+			session := fasthttpsession.NewSession(config)
+			err := session.SetProvider(memory.NewProvider(), &memory.Config{})
+			if test.wantErr {
+				if !errors.Is(err, fasthttpsession.ErrSigningKeyTooShort) {
+					t.Fatalf("SetProvider error = %v, want ErrSigningKeyTooShort", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetProvider error: %v", err)
+			}
+			session.Close(context.Background())
+		})
+	}
+}
+
+func TestSessionIdDefaultExtractors(t *testing.T) {
+	tests := []struct {
+		name       string
+		cookieName string
+		request    func(ctx *fasthttp.RequestCtx)
+		want       string
+	}{
+		{"cookie", "", func(ctx *fasthttp.RequestCtx) { ctx.Request.Header.SetCookie("_fssid_", "cookie-id") }, "cookie-id"},
+		{"changed cookie name", "sid", func(ctx *fasthttp.RequestCtx) { ctx.Request.Header.SetCookie("sid", "cookie-id") }, "cookie-id"},
+		{"previous cookie name", "sid", func(ctx *fasthttp.RequestCtx) { ctx.Request.Header.SetCookie("_fssid_", "cookie-id") }, ""},
+		{"query", "", func(ctx *fasthttp.RequestCtx) { ctx.Request.SetRequestURI("/?sid=query-id") }, "query-id"},
+		{"header", "", func(ctx *fasthttp.RequestCtx) { ctx.Request.Header.Set("X-Session-Id", "header-id") }, "header-id"},
+		{"cookie first", "", func(ctx *fasthttp.RequestCtx) {
+			ctx.Request.Header.SetCookie("_fssid_", "cookie-id")
+			ctx.Request.Header.Set("X-Session-Id", "header-id")
+		}, "cookie-id"},
+		{"none", "", func(ctx *fasthttp.RequestCtx) {}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fasthttpsession.NewDefaultConfig()
+			config.SessionIdInURLQuery = true
+			config.SessionNameInUrlQuery = "sid"
+			config.SessionIdInHttpHeader = true
+			config.SessionNameInHttpHeader = "X-Session-Id"
+			session := fasthttpsession.NewSession(config)
+			session.ChangeCookieName(test.cookieName)
+
+			ctx := newTestCtx("", "")
+			test.request(ctx)
+			if got := session.GetSessionId(ctx); got != test.want {
+				t.Fatalf("GetSessionId = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// memory provider whose gc panics on the first calls
+type panicGCProvider struct {
+	*memory.Provider
+	panics int32
+	calls  int32
+}
+
+func (p *panicGCProvider) GC() {
+	if atomic.AddInt32(&p.calls, 1) <= p.panics {
+		panic("gc panic")
+	}
+	p.Provider.GC()
+}
+
+// logger counting the errors
+type errorCountLogger struct {
+	fasthttpsession.Logger
+	errors int32
+}
+
+func (l *errorCountLogger) Error(msg string, keysAndValues ...interface{}) {
+	atomic.AddInt32(&l.errors, 1)
+}
+
+func TestGCPanic(t *testing.T) {
+	tests := []struct {
+		name   string
+		panics int32
+	}{
+		{"first gc panics", 1},
+		{"first two gcs panic", 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := &panicGCProvider{Provider: memory.NewProvider(), panics: test.panics}
+			logger := &errorCountLogger{Logger: fasthttpsession.NewNopLogger()}
+			config := fasthttpsession.NewDefaultConfig()
+			config.GCLifetime = 1
+			config.Logger = logger
+			session := fasthttpsession.NewSession(config)
+			if err := session.SetProvider(provider, &memory.Config{}); err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close(context.Background())
+
+			// the gc keeps running after the panics
+			deadline := time.Now().Add(time.Duration(test.panics+3) * time.Second)
+			for atomic.LoadInt32(&provider.calls) <= test.panics {
+				if time.Now().After(deadline) {
+					t.Fatalf("gc calls = %d, want more than %d", atomic.LoadInt32(&provider.calls), test.panics)
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+			if logged := atomic.LoadInt32(&logger.errors); logged != test.panics {
+				t.Fatalf("logged errors = %d, want %d", logged, test.panics)
+			}
+		})
+	}
+}
+
+// memory provider recording whether the expire func is set
+type expireNotifyProvider struct {
+	*memory.Provider
+	expireFuncSet bool
+}
+
+func (p *expireNotifyProvider) SetExpireFunc(expireFunc func(sessionId string)) {
+	p.expireFuncSet = expireFunc != nil
+	p.Provider.SetExpireFunc(expireFunc)
+}
+
+func TestSetProviderExpireFunc(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(config *fasthttpsession.Config)
+		want   bool
+	}{
+		{"no consumer", func(config *fasthttpsession.Config) {}, false},
+		{"OnExpire hook", func(config *fasthttpsession.Config) {
+			config.Hooks.OnExpire = func(sessionId string) {}
+		}, true},
+		{"metrics", func(config *fasthttpsession.Config) {
+			config.Metrics = fasthttpsession.NewPrometheusMetrics()
+		}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := fasthttpsession.NewDefaultConfig()
+			test.config(config)
+			provider := &expireNotifyProvider{Provider: memory.NewProvider()}
+			newTestProviderSession(t, config, provider, &memory.Config{})
+			if provider.expireFuncSet != test.want {
+				t.Fatalf("expire func set = %v, want %v", provider.expireFuncSet, test.want)
+			}
+		})
+	}
+}
+
+func TestMetricsLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		want   []string
+	}{
+		{"provider name", []string{"", ""}, []string{`fasthttpsession_sessions_total{provider="memory",event="created"} 2`}},
+		{"session labels", []string{"front", "admin"}, []string{
+			`fasthttpsession_sessions_total{provider="admin",event="created"} 1`,
+			`fasthttpsession_sessions_total{provider="front",event="created"} 1`,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metrics := fasthttpsession.NewPrometheusMetrics()
+			for _, label := range test.labels {
+				config := fasthttpsession.NewDefaultConfig()
+				config.Metrics = metrics
+				config.MetricsLabel = label
+				newTestSessionId(t, newTestSession(t, config))
+			}
+			buf := &bytes.Buffer{}
+			if _, err := metrics.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !bytes.Contains(buf.Bytes(), []byte(want+"\n")) {
+					t.Fatalf("metrics text misses %s, got\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
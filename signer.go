@@ -0,0 +1,70 @@
+package fasthttpsession
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrSessionIdMalformed = errors.New("session id is malformed, it is empty or not signed")
+	ErrSessionIdSignature = errors.New("session id signature is invalid")
+	ErrSessionIdInvalid   = errors.New("session id is rejected by the session id validator")
+	ErrSigningKeyTooShort = errors.New("session signing key is empty or shorter than 32 bytes")
+)
+
+// min size of a signing key, the HMAC-SHA256 output size
+const minSigningKeySize = sha256.Size
+
+// Signer signs session ids with HMAC-SHA256, id.signature
+// the first key signs, all keys verify, so previous keys can be kept during a rotation
+type Signer struct {
+	keys [][]byte
+}
+
+// return new Signer
+func NewSigner(keys ...[]byte) *Signer {
+	return &Signer{keys: keys}
+}
+
+// sign sessionId, id.signature
+func (s *Signer) Sign(sessionId string) string {
+	return sessionId + "." + base64.RawURLEncoding.EncodeToString(s.signature(s.keys[0], sessionId))
+}
+
+// verify the signed value, returns the session id
+func (s *Signer) Verify(value string) (string, error) {
+	dot := strings.LastIndexByte(value, '.')
+	if dot <= 0 {
+		return "", ErrSessionIdMalformed
+	}
+	sessionId := value[:dot]
+	signature, err := base64.RawURLEncoding.DecodeString(value[dot+1:])
+	if err != nil {
+		return "", ErrSessionIdSignature
+	}
+	for _, key := range s.keys {
+		if hmac.Equal(signature, s.signature(key, sessionId)) {
+			return sessionId, nil
+		}
+	}
+	return "", ErrSessionIdSignature
+}
+
+func (s *Signer) signature(key []byte, sessionId string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sessionId))
+	return mac.Sum(nil)
+}
+
+// check the signing keys, a short key makes the signature forgeable
+func checkSigningKeys(keys [][]byte) error {
+	for _, key := range keys {
+		if len(key) < minSigningKeySize {
+			return ErrSigningKeyTooShort
+		}
+	}
+	return nil
+}
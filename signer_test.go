@@ -0,0 +1,126 @@
+package fasthttpsession
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var (
+	testKeyA = bytes.Repeat([]byte("a"), 32)
+	testKeyB = bytes.Repeat([]byte("b"), 32)
+	testKeyC = bytes.Repeat([]byte("c"), 32)
+)
+
+func TestSignerVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		signKeys   [][]byte
+		verifyKeys [][]byte
+		value      func(signed string) string
+		wantErr    error
+	}{
+		{
+			name:       "same key",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyA},
+		},
+		{
+			name:       "signed by the previous key during a rotation",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyB, testKeyA},
+		},
+		{
+			name:       "signed by the new key during a rotation",
+			signKeys:   [][]byte{testKeyB, testKeyA},
+			verifyKeys: [][]byte{testKeyB, testKeyA},
+		},
+		{
+			name:       "previous key removed",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyB, testKeyC},
+			wantErr:    ErrSessionIdSignature,
+		},
+		{
+			name:       "tampered session id",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyA},
+			value:      func(signed string) string { return "other" + signed[len("sessionId"):] },
+			wantErr:    ErrSessionIdSignature,
+		},
+		{
+			name:       "invalid signature encoding",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyA},
+			value:      func(signed string) string { return "sessionId.!!!" },
+			wantErr:    ErrSessionIdSignature,
+		},
+		{
+			name:       "not signed",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyA},
+			value:      func(signed string) string { return "sessionId" },
+			wantErr:    ErrSessionIdMalformed,
+		},
+		{
+			name:       "no session id",
+			signKeys:   [][]byte{testKeyA},
+			verifyKeys: [][]byte{testKeyA},
+			value:      func(signed string) string { return signed[len("sessionId"):] },
+			wantErr:    ErrSessionIdMalformed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := NewSigner(test.signKeys...).Sign("sessionId")
+			if test.value != nil {
+				value = test.value(value)
+			}
+			sessionId, err := NewSigner(test.verifyKeys...).Verify(value)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Verify(%s) error = %v, want %v", value, err, test.wantErr)
+			}
+			if err == nil && sessionId != "sessionId" {
+				t.Fatalf("Verify(%s) = %s, want sessionId", value, sessionId)
+			}
+		})
+	}
+}
+
+func TestCheckSigningKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		wantErr error
+	}{
+		{"no keys", nil, nil},
+		{"32 bytes", [][]byte{testKeyA}, nil},
+		{"empty key", [][]byte{testKeyA, {}}, ErrSigningKeyTooShort},
+		{"short key", [][]byte{[]byte("secret")}, ErrSigningKeyTooShort},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := checkSigningKeys(test.keys); !errors.Is(err, test.wantErr) {
+				t.Fatalf("checkSigningKeys error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigSessionIdSigning(t *testing.T) {
+	config := NewDefaultConfig()
+	config.SigningKeys = [][]byte{testKeyA}
+	NewSession(config)
+
+	value, err := config.EncodeSessionId("sessionId")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value == "sessionId" {
+		t.Fatal("EncodeSessionId did not sign the session id")
+	}
+	sessionId, err := config.DecodeSessionId(value)
+	if err != nil || sessionId != "sessionId" {
+		t.Fatalf("DecodeSessionId(%s) = %s, %v, want sessionId", value, sessionId, err)
+	}
+}
@@ -1,11 +1,14 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/brunohass/fasthttpsession"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -25,17 +28,55 @@ type sessionDao struct {
 	tableName   string
 }
 
+// session exists by sessionId
+func (dao *sessionDao) sessionIdIsExists(ctx context.Context, sessionId string) (bool, error) {
+	sqlStr := fmt.Sprintf("SELECT count(*) as total FROM %s WHERE session_id=?", dao.tableName)
+	res, err := dao.getRow(ctx, sqlStr, sessionId)
+	if err != nil {
+		return false, err
+	}
+	total, _ := strconv.Atoi(string(res["total"]))
+	return total > 0, nil
+}
+
+// get the columns of the session table missing from columns
+func (dao *sessionDao) getMissingColumns(ctx context.Context, columns []string) ([]string, error) {
+	rows, err := dao.sqlite3Conn.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1=0", dao.tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tableColumns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	missingColumns := []string{}
+	for _, column := range columns {
+		found := false
+		for _, tableColumn := range tableColumns {
+			if strings.EqualFold(tableColumn, column) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missingColumns = append(missingColumns, column)
+		}
+	}
+	return missingColumns, nil
+}
+
 // get session by sessionId
-func (dao *sessionDao) getSessionBySessionId(sessionId string) (session map[string][]byte, err error) {
+func (dao *sessionDao) getSessionBySessionId(ctx context.Context, sessionId string) (session map[string][]byte, err error) {
 
 	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE session_id=?", dao.tableName)
-	return dao.getRow(sqlStr, sessionId)
+	return dao.getRow(ctx, sqlStr, sessionId)
 }
 
 // count sessionId
-func (dao *sessionDao) countSessions() int {
+func (dao *sessionDao) countSessions(ctx context.Context) int {
 	sqlStr := fmt.Sprintf("SELECT count(*) as total FROM %s", dao.tableName)
-	res, err := dao.getRow(sqlStr)
+	res, err := dao.getRow(ctx, sqlStr)
 	if err != nil {
 		return 0
 	}
@@ -43,41 +84,132 @@ func (dao *sessionDao) countSessions() int {
 	return total
 }
 
-// update session by sessionId
-func (dao *sessionDao) updateBySessionId(sessionId string, contents string, lastActiveTime int64) (int64, error) {
-	sqlStr := fmt.Sprintf("UPDATE %s SET contents=?,last_active=? WHERE session_id=?", dao.tableName)
-	return dao.execute(sqlStr, contents, lastActiveTime, sessionId)
+// update session by sessionId, if its version is still version
+// the version is incremented, no row is affected if it moved
+func (dao *sessionDao) updateBySessionId(ctx context.Context, sessionId string, contents string, lastActiveTime int64, lifeTime int64, userID string, version int64) (int64, error) {
+	sqlStr := fmt.Sprintf("UPDATE %s SET contents=?,last_active=?,lifetime=?,user_id=?,version=version+1 WHERE session_id=? AND version=?", dao.tableName)
+	return dao.execute(ctx, sqlStr, contents, lastActiveTime, lifeTime, userID, sessionId, version)
+}
+
+// update session last active time by sessionId
+func (dao *sessionDao) updateLastActiveBySessionId(ctx context.Context, sessionId string, lastActiveTime int64) (int64, error) {
+	sqlStr := fmt.Sprintf("UPDATE %s SET last_active=? WHERE session_id=?", dao.tableName)
+	return dao.execute(ctx, sqlStr, lastActiveTime, sessionId)
 }
 
 // delete session by sessionId
-func (dao *sessionDao) deleteBySessionId(sessionId string) (int64, error) {
+func (dao *sessionDao) deleteBySessionId(ctx context.Context, sessionId string) (int64, error) {
 	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE session_id=?", dao.tableName)
-	return dao.execute(sqlStr, sessionId)
+	return dao.execute(ctx, sqlStr, sessionId)
+}
+
+// delete session by maxLifeTime, or by its own lifetime if set
+func (dao *sessionDao) deleteSessionByMaxLifeTime(ctx context.Context, maxLifeTime int64) (int64, error) {
+	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE (lifetime=0 AND last_active<=?) OR (lifetime>0 AND last_active+lifetime<=?)", dao.tableName)
+	now := time.Now().Unix()
+	return dao.execute(ctx, sqlStr, now-maxLifeTime, now)
+}
+
+// delete session by absoluteLifeTime
+func (dao *sessionDao) deleteSessionByAbsoluteLifeTime(ctx context.Context, absoluteLifeTime int64) (int64, error) {
+	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE created_at<=?", dao.tableName)
+	createdTime := time.Now().Unix() - absoluteLifeTime
+	return dao.execute(ctx, sqlStr, createdTime)
+}
+
+// expired sessions condition, by the idle lifetime or the absolute lifetime if absoluteLifeTime > 0
+func (dao *sessionDao) expiredCondition(maxLifeTime int64, absoluteLifeTime int64) (string, []interface{}) {
+	now := time.Now().Unix()
+	createdTime := int64(-1)
+	if absoluteLifeTime > 0 {
+		createdTime = now - absoluteLifeTime
+	}
+	return "((lifetime=0 AND last_active<=?) OR (lifetime>0 AND last_active+lifetime<=?) OR created_at<=?)", []interface{}{now - maxLifeTime, now, createdTime}
 }
 
-// delete session by maxLifeTime
-func (dao *sessionDao) deleteSessionByMaxLifeTime(maxLifeTime int64) (int64, error) {
-	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE last_active<=?", dao.tableName)
-	lastTime := time.Now().Unix() - maxLifeTime
-	return dao.execute(sqlStr, lastTime)
+// get the ids of the expired sessions
+func (dao *sessionDao) getExpiredSessionIds(ctx context.Context, maxLifeTime int64, absoluteLifeTime int64) ([]string, error) {
+	condition, args := dao.expiredCondition(maxLifeTime, absoluteLifeTime)
+	sqlStr := fmt.Sprintf("SELECT session_id FROM %s WHERE %s", dao.tableName, condition)
+	rows, err := dao.getRows(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	sessionIds := make([]string, 0, len(rows))
+	for _, row := range rows {
+		sessionIds = append(sessionIds, string(row["session_id"]))
+	}
+	return sessionIds, nil
+}
+
+// delete session by sessionId if it is still expired
+func (dao *sessionDao) deleteExpiredBySessionId(ctx context.Context, sessionId string, maxLifeTime int64, absoluteLifeTime int64) (int64, error) {
+	condition, args := dao.expiredCondition(maxLifeTime, absoluteLifeTime)
+	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE session_id=? AND %s", dao.tableName, condition)
+	return dao.execute(ctx, sqlStr, append([]interface{}{sessionId}, args...)...)
 }
 
 // insert new session
-func (dao *sessionDao) insert(sessionId string, contents string, lastActiveTime int64) (int64, error) {
-	sqlStr := fmt.Sprintf("INSERT INTO %s (session_id, contents, last_active) VALUES (?,?,?)", dao.tableName)
-	return dao.execute(sqlStr, sessionId, contents, lastActiveTime)
+func (dao *sessionDao) insert(ctx context.Context, sessionId string, contents string, lastActiveTime int64, createdTime int64, lifeTime int64, userID string) (int64, error) {
+	sqlStr := fmt.Sprintf("INSERT INTO %s (session_id, contents, last_active, created_at, lifetime, user_id) VALUES (?,?,?,?,?,?)", dao.tableName)
+	return dao.execute(ctx, sqlStr, sessionId, contents, lastActiveTime, createdTime, lifeTime, userID)
+}
+
+// get the ids of the sessions of userID, which are not expired
+func (dao *sessionDao) getSessionIdsByUserId(ctx context.Context, userID string, maxLifeTime int64, absoluteLifeTime int64) ([]string, error) {
+	condition, args := dao.expiredCondition(maxLifeTime, absoluteLifeTime)
+	sqlStr := fmt.Sprintf("SELECT session_id FROM %s WHERE user_id=? AND NOT %s", dao.tableName, condition)
+	rows, err := dao.getRows(ctx, sqlStr, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	sessionIds := make([]string, 0, len(rows))
+	for _, row := range rows {
+		sessionIds = append(sessionIds, string(row["session_id"]))
+	}
+	return sessionIds, nil
+}
+
+// get the session by sessionId if it is not expired
+func (dao *sessionDao) getUnexpiredSessionBySessionId(ctx context.Context, sessionId string, maxLifeTime int64, absoluteLifeTime int64) (map[string][]byte, error) {
+	condition, args := dao.expiredCondition(maxLifeTime, absoluteLifeTime)
+	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE session_id=? AND NOT %s", dao.tableName, condition)
+	return dao.getRow(ctx, sqlStr, append([]interface{}{sessionId}, args...)...)
+}
+
+// get the sessions after sessionId in session id order, which are not expired
+func (dao *sessionDao) getSessionsAfterSessionId(ctx context.Context, sessionId string, limit int, maxLifeTime int64, absoluteLifeTime int64) ([]map[string][]byte, error) {
+	condition, args := dao.expiredCondition(maxLifeTime, absoluteLifeTime)
+	sqlStr := fmt.Sprintf("SELECT * FROM %s WHERE session_id>? AND NOT %s ORDER BY session_id LIMIT ?", dao.tableName, condition)
+	args = append([]interface{}{sessionId}, args...)
+	return dao.getRows(ctx, sqlStr, append(args, limit)...)
+}
+
+// delete the sessions of userID
+func (dao *sessionDao) deleteByUserId(ctx context.Context, userID string) (int64, error) {
+	sqlStr := fmt.Sprintf("DELETE FROM %s WHERE user_id=?", dao.tableName)
+	return dao.execute(ctx, sqlStr, userID)
 }
 
 // get rows
 // return []map[string][]byte
-func (dao *sessionDao) getRows(sql string, args ...interface{}) (results []map[string][]byte, err error) {
+func (dao *sessionDao) getRows(ctx context.Context, sql string, args ...interface{}) (results []map[string][]byte, err error) {
+	ctx, span := dao.startSpan(ctx, sql)
+	defer func() {
+		size := 0
+		for _, row := range results {
+			size += len(row["contents"])
+		}
+		span.SetAttributes(fasthttpsession.IntAttribute(fasthttpsession.AttributeSize, size))
+		fasthttpsession.EndSpan(span, err)
+	}()
 
-	stmt, err := dao.sqlite3Conn.Prepare(sql)
+	stmt, err := dao.sqlite3Conn.PrepareContext(ctx, sql)
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return
 	}
@@ -106,12 +238,13 @@ func (dao *sessionDao) getRows(sql string, args ...interface{}) (results []map[s
 		}
 		results = append(results, row)
 	}
+	err = rows.Err()
 	return
 }
 
 // get row
-func (dao *sessionDao) getRow(sql string, args ...interface{}) (res map[string][]byte, err error) {
-	rows, err := dao.getRows(sql, args...)
+func (dao *sessionDao) getRow(ctx context.Context, sql string, args ...interface{}) (res map[string][]byte, err error) {
+	rows, err := dao.getRows(ctx, sql, args...)
 	if err != nil {
 		return
 	}
@@ -122,15 +255,29 @@ func (dao *sessionDao) getRow(sql string, args ...interface{}) (res map[string][
 }
 
 // execute(insert, update, delete)
-func (dao *sessionDao) execute(sql string, args ...interface{}) (int64, error) {
-	stmt, err := dao.sqlite3Conn.Prepare(sql)
+func (dao *sessionDao) execute(ctx context.Context, sql string, args ...interface{}) (affected int64, err error) {
+	ctx, span := dao.startSpan(ctx, sql)
+	defer func() {
+		fasthttpsession.EndSpan(span, err)
+	}()
+
+	stmt, err := dao.sqlite3Conn.PrepareContext(ctx, sql)
 	if err != nil {
 		return 0, err
 	}
 	defer stmt.Close()
-	rows, err := stmt.Exec(args...)
+	rows, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
 		return 0, err
 	}
 	return rows.RowsAffected()
 }
+
+// start a span of the sql statement with the tracer carried by ctx
+func (dao *sessionDao) startSpan(ctx context.Context, sql string) (context.Context, fasthttpsession.Span) {
+	operation, _, _ := strings.Cut(sql, " ")
+	return fasthttpsession.StartSpan(ctx, "sqlite3 "+operation,
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBSystem, "sqlite"),
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBOperation, operation),
+		fasthttpsession.StringAttribute(fasthttpsession.AttributeDBStatement, sql))
+}
@@ -1,8 +1,12 @@
 package sqlite3
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -17,23 +21,33 @@ import (
 //    `session_id` varchar(64) NOT NULL DEFAULT '',
 //    `contents` TEXT NOT NULL,
 //    `last_active` int(10) NOT NULL DEFAULT '0',
+//    `created_at` int(10) NOT NULL DEFAULT '0',
+//    `lifetime` int(10) NOT NULL DEFAULT '0',
+//    `version` bigint NOT NULL DEFAULT '0',
+//    `user_id` varchar(64) NOT NULL DEFAULT '',
 //    PRIMARY KEY (`session_id`),
 //  )
 //  create index last_active on session (last_active);
+//  create index created_at on session (created_at);
+//  create index user_id on session (user_id);
 //
 
 const ProviderName = "sqlite3"
 
-var (
-	provider = NewProvider()
-	encrypt  = fasthttpsession.NewEncrypt()
-)
+// columns added to the session table since its first version
+var requiredColumns = []string{"created_at", "lifetime", "version", "user_id"}
+
+var encrypt = fasthttpsession.NewEncrypt()
 
 type Provider struct {
-	config      *Config
-	values      *fasthttpsession.CCMap
-	sessionDao  *sessionDao
-	maxLifeTime int64
+	config           *Config
+	values           *fasthttpsession.CCMap
+	sessionDao       *sessionDao
+	locker           *fasthttpsession.SessionLocker
+	maxLifeTime      int64
+	absoluteLifeTime int64
+	expireFunc       func(sessionId string)
+	logger           fasthttpsession.Logger
 }
 
 // new sqlite3 provider
@@ -42,6 +56,8 @@ func NewProvider() *Provider {
 		config:     &Config{},
 		values:     fasthttpsession.NewDefaultCCMap(),
 		sessionDao: &sessionDao{},
+		locker:     fasthttpsession.NewSessionLocker(),
+		logger:     fasthttpsession.NewNopLogger(),
 	}
 }
 
@@ -75,7 +91,34 @@ func (sp *Provider) Init(lifeTime int64, sqlite3Config fasthttpsession.ProviderC
 	sessionDao.sqlite3Conn.SetMaxIdleConns(sp.config.SetMaxIdleConn)
 
 	sp.sessionDao = sessionDao
-	return sessionDao.sqlite3Conn.Ping()
+	err = sessionDao.sqlite3Conn.Ping()
+	if err != nil {
+		return err
+	}
+	// a table created by a previous version misses the new columns, see the README upgrade notes
+	missingColumns, err := sessionDao.getMissingColumns(context.Background(), requiredColumns)
+	if err != nil {
+		return err
+	}
+	if len(missingColumns) > 0 {
+		return fmt.Errorf("session sqlite3 provider init error, table %s misses the columns %s, see the table structure", sp.config.TableName, strings.Join(missingColumns, ", "))
+	}
+	return nil
+}
+
+// set the absolute session lifetime(s), 0 means no limit
+func (sp *Provider) SetAbsoluteLifetime(absoluteLifeTime int64) {
+	sp.absoluteLifeTime = absoluteLifeTime
+}
+
+// set the func called with the sessions expired by GC
+func (sp *Provider) SetExpireFunc(expireFunc func(sessionId string)) {
+	sp.expireFunc = expireFunc
+}
+
+// set the logger of the failures which can not be returned, in GC
+func (sp *Provider) SetLogger(logger fasthttpsession.Logger) {
+	sp.logger = logger
 }
 
 // not need gc
@@ -85,25 +128,47 @@ func (sp *Provider) NeedGC() bool {
 
 // session sqlite3 provider not need garbage collection
 func (sp *Provider) GC() {
-	sp.sessionDao.deleteSessionByMaxLifeTime(sp.maxLifeTime)
+	if sp.expireFunc != nil {
+		sp.gcExpired(context.Background())
+		return
+	}
+	_, err := sp.sessionDao.deleteSessionByMaxLifeTime(context.Background(), sp.maxLifeTime)
+	if err != nil {
+		sp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+	}
+	if sp.absoluteLifeTime > 0 {
+		_, err = sp.sessionDao.deleteSessionByAbsoluteLifeTime(context.Background(), sp.absoluteLifeTime)
+		if err != nil {
+			sp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		}
+	}
 }
 
 // read session store by session id
 func (sp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, error) {
+	return sp.ReadStoreContext(context.Background(), sessionId)
+}
+
+// read session store by session id with context
+func (sp *Provider) ReadStoreContext(ctx context.Context, sessionId string) (fasthttpsession.SessionStore, error) {
 
-	sessionValue, err := sp.sessionDao.getSessionBySessionId(sessionId)
+	sessionValue, err := sp.sessionDao.getSessionBySessionId(ctx, sessionId)
 	if err != nil {
 		return nil, err
 	}
 	if len(sessionValue) == 0 {
-		_, err := sp.sessionDao.insert(sessionId, "", time.Now().Unix())
+		_, err := sp.sessionDao.insert(ctx, sessionId, "", time.Now().Unix(), time.Now().Unix(), 0, "")
 		if err != nil {
 			return nil, err
 		}
-		return NewSqLite3Store(sessionId), nil
+		return NewSqLite3Store(sp, sessionId), nil
 	}
+	// the version column is the session version
+	version, _ := strconv.ParseInt(string(sessionValue["version"]), 10, 64)
 	if len(sessionValue["contents"]) == 0 {
-		return NewSqLite3Store(sessionId), nil
+		store := NewSqLite3Store(sp, sessionId)
+		store.SetVersion(version)
+		return store, nil
 	}
 
 	data, err := sp.config.UnSerializeFunc(sessionValue["contents"])
@@ -111,51 +176,169 @@ func (sp *Provider) ReadStore(sessionId string) (fasthttpsession.SessionStore, e
 		return nil, err
 	}
 
-	return NewSqLite3StoreData(sessionId, data), nil
+	store := NewSqLite3StoreData(sp, sessionId, data)
+	store.SetVersion(version)
+	store.SetSize(len(sessionValue["contents"]))
+	return store, nil
 }
 
 // regenerate session
 func (sp *Provider) Regenerate(oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
+	return sp.RegenerateContext(context.Background(), oldSessionId, sessionId)
+}
+
+// regenerate session with context
+func (sp *Provider) RegenerateContext(ctx context.Context, oldSessionId string, sessionId string) (fasthttpsession.SessionStore, error) {
 
-	sessionValue, err := sp.sessionDao.getSessionBySessionId(oldSessionId)
+	sessionValue, err := sp.sessionDao.getSessionBySessionId(ctx, oldSessionId)
 	if err != nil {
 		return nil, err
 	}
 	if len(sessionValue) == 0 {
 		// old sessionId not exists, insert new sessionId
-		_, err := sp.sessionDao.insert(sessionId, "", time.Now().Unix())
+		_, err := sp.sessionDao.insert(ctx, sessionId, "", time.Now().Unix(), time.Now().Unix(), 0, "")
 		if err != nil {
 			return nil, err
 		}
-		return NewSqLite3Store(sessionId), nil
+		return NewSqLite3Store(sp, sessionId), nil
 	}
 
 	// delete old session
-	_, err = sp.sessionDao.deleteBySessionId(oldSessionId)
+	_, err = sp.sessionDao.deleteBySessionId(ctx, oldSessionId)
 	if err != nil {
 		return nil, err
 	}
-	// insert new session
-	_, err = sp.sessionDao.insert(sessionId, string(sessionValue["contents"]), time.Now().Unix())
+	// insert new session, keep the create time, lifetime and user id
+	createdTime, _ := strconv.ParseInt(string(sessionValue["created_at"]), 10, 64)
+	if createdTime == 0 {
+		createdTime = time.Now().Unix()
+	}
+	lifeTime, _ := strconv.ParseInt(string(sessionValue["lifetime"]), 10, 64)
+	_, err = sp.sessionDao.insert(ctx, sessionId, string(sessionValue["contents"]), time.Now().Unix(), createdTime, lifeTime, string(sessionValue["user_id"]))
 	if err != nil {
 		return nil, err
 	}
 
-	return sp.ReadStore(sessionId)
+	return sp.ReadStoreContext(ctx, sessionId)
 }
 
 // destroy session by sessionId
 func (sp *Provider) Destroy(sessionId string) error {
-	_, err := sp.sessionDao.deleteBySessionId(sessionId)
+	return sp.DestroyContext(context.Background(), sessionId)
+}
+
+// destroy session by sessionId with context
+func (sp *Provider) DestroyContext(ctx context.Context, sessionId string) error {
+	_, err := sp.sessionDao.deleteBySessionId(ctx, sessionId)
 	return err
 }
 
+// lock session by sessionId
+// sqlite has no lock service, the lock only covers the requests of this process
+func (sp *Provider) Lock(ctx context.Context, sessionId string) (func() error, error) {
+	return sp.locker.Lock(ctx, sessionId)
+}
+
+// session exists by sessionId
+func (sp *Provider) Exists(ctx context.Context, sessionId string) (bool, error) {
+	return sp.sessionDao.sessionIdIsExists(ctx, sessionId)
+}
+
+// session ids of userID
+func (sp *Provider) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	return sp.sessionDao.getSessionIdsByUserId(ctx, userID, sp.maxLifeTime, sp.absoluteLifeTime)
+}
+
+// destroy all the sessions of userID
+func (sp *Provider) DestroyUserSessions(ctx context.Context, userID string) error {
+	_, err := sp.sessionDao.deleteByUserId(ctx, userID)
+	return err
+}
+
+// scan the sessions in session id order, the cursor is the last session id
+func (sp *Provider) Scan(ctx context.Context, cursor string, limit int) ([]fasthttpsession.SessionInfo, string, error) {
+	rows, err := sp.sessionDao.getSessionsAfterSessionId(ctx, cursor, limit, sp.maxLifeTime, sp.absoluteLifeTime)
+	if err != nil {
+		return nil, "", err
+	}
+	infos := make([]fasthttpsession.SessionInfo, 0, len(rows))
+	for _, row := range rows {
+		info, _, err := sp.getRowInfo(row)
+		if err != nil {
+			return nil, "", err
+		}
+		infos = append(infos, info)
+	}
+	nextCursor := ""
+	if len(infos) == limit {
+		nextCursor = infos[len(infos)-1].SessionId
+	}
+	return infos, nextCursor, nil
+}
+
+// peek the session data, the last active time is not updated
+func (sp *Provider) Peek(ctx context.Context, sessionId string) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	row, err := sp.sessionDao.getUnexpiredSessionBySessionId(ctx, sessionId, sp.maxLifeTime, sp.absoluteLifeTime)
+	if err != nil {
+		return fasthttpsession.SessionInfo{}, nil, err
+	}
+	if len(row) == 0 {
+		return fasthttpsession.SessionInfo{}, nil, fasthttpsession.ErrSessionNotFound
+	}
+	return sp.getRowInfo(row)
+}
+
 // session values count
 func (sp *Provider) Count() int {
-	return sp.sessionDao.countSessions()
+	return sp.sessionDao.countSessions(context.Background())
+}
+
+// close the sqlite3 db
+func (sp *Provider) Close() error {
+	if sp.sessionDao.sqlite3Conn == nil {
+		return nil
+	}
+	return sp.sessionDao.sqlite3Conn.Close()
+}
+
+// get the session info and data of a session row
+func (sp *Provider) getRowInfo(row map[string][]byte) (fasthttpsession.SessionInfo, map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	if len(row["contents"]) > 0 {
+		var err error
+		data, err = sp.config.UnSerializeFunc(row["contents"])
+		if err != nil {
+			return fasthttpsession.SessionInfo{}, nil, err
+		}
+	}
+	store := &fasthttpsession.Store{}
+	store.Init(string(row["session_id"]), data)
+
+	info := store.Info()
+	createdTime, _ := strconv.ParseInt(string(row["created_at"]), 10, 64)
+	info.CreatedAt = time.Unix(createdTime, 0)
+	lastActiveTime, _ := strconv.ParseInt(string(row["last_active"]), 10, 64)
+	info.LastActiveAt = time.Unix(lastActiveTime, 0)
+	info.Size = len(row["contents"])
+	return info, store.GetAll(), nil
 }
 
-// register session provider
-func init() {
-	fasthttpsession.Register(ProviderName, provider)
+// delete the expired sessions one by one, to report them to the expire func
+func (sp *Provider) gcExpired(ctx context.Context) {
+	sessionIds, err := sp.sessionDao.getExpiredSessionIds(ctx, sp.maxLifeTime, sp.absoluteLifeTime)
+	if err != nil {
+		sp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "error", err)
+		return
+	}
+	for _, sessionId := range sessionIds {
+		// the session may have been saved since it was selected
+		rows, err := sp.sessionDao.deleteExpiredBySessionId(ctx, sessionId, sp.maxLifeTime, sp.absoluteLifeTime)
+		if err != nil {
+			sp.logger.Error("session gc error", "provider", ProviderName, "op", "gc", "sessionId", sessionId, "error", err)
+			continue
+		}
+		if rows > 0 {
+			sp.expireFunc(sessionId)
+		}
+	}
 }
@@ -0,0 +1,156 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brunohass/fasthttpsession"
+)
+
+const testTable = `CREATE TABLE session (
+	session_id varchar(64) NOT NULL DEFAULT '',
+	contents TEXT NOT NULL,
+	last_active int NOT NULL DEFAULT 0,
+	created_at int NOT NULL DEFAULT 0,
+	lifetime int NOT NULL DEFAULT 0,
+	version bigint NOT NULL DEFAULT 0,
+	user_id varchar(64) NOT NULL DEFAULT '',
+	PRIMARY KEY (session_id)
+)`
+
+// create the session table in a new db, return the db path
+func createTestDB(t *testing.T, table string) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "session.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(table); err != nil {
+		t.Fatal(err)
+	}
+	return dbPath
+}
+
+func TestInitMissingColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		missing string
+	}{
+		{
+			name:  "current table",
+			table: testTable,
+		},
+		{
+			name:    "first version table",
+			table:   "CREATE TABLE session (session_id varchar(64) NOT NULL DEFAULT '', contents TEXT NOT NULL, last_active int NOT NULL DEFAULT 0)",
+			missing: strings.Join(requiredColumns, ", "),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := NewProvider()
+			err := provider.Init(60, NewConfigWith(createTestDB(t, test.table), "session"))
+			defer provider.Close()
+			if test.missing == "" {
+				if err != nil {
+					t.Fatalf("Init error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "misses the columns "+test.missing) {
+				t.Fatalf("Init error = %v, want the missing columns %s", err, test.missing)
+			}
+		})
+	}
+}
+
+// new sqlite3 provider of lifeTime(s) on a new db
+func newTestProvider(t *testing.T, lifeTime int64) *Provider {
+	t.Helper()
+	provider := NewProvider()
+	if err := provider.Init(lifeTime, NewConfigWith(createTestDB(t, testTable), "session")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+func TestExpiredSessionsHidden(t *testing.T) {
+	tests := []struct {
+		name             string
+		absoluteLifeTime int64
+		update           string
+		found            bool
+	}{
+		{
+			name:   "active session",
+			update: "UPDATE session SET last_active=last_active",
+			found:  true,
+		},
+		{
+			name:   "idle lifetime reached",
+			update: "UPDATE session SET last_active=last_active-120",
+		},
+		{
+			name:   "session lifetime reached",
+			update: "UPDATE session SET lifetime=30, last_active=last_active-30",
+		},
+		{
+			name:             "absolute lifetime reached",
+			absoluteLifeTime: 60,
+			update:           "UPDATE session SET created_at=created_at-120",
+		},
+		{
+			name:             "absolute lifetime not reached",
+			absoluteLifeTime: 600,
+			update:           "UPDATE session SET created_at=created_at-120",
+			found:            true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestProvider(t, 60)
+			provider.SetAbsoluteLifetime(test.absoluteLifeTime)
+			sessionStore, err := provider.ReadStore("s1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionStore.SetUserID("alice")
+			if err := sessionStore.(*Store).SaveContext(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := provider.sessionDao.sqlite3Conn.Exec(test.update); err != nil {
+				t.Fatal(err)
+			}
+
+			infos, _, err := provider.Scan(context.Background(), "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := len(infos) == 1; found != test.found {
+				t.Fatalf("Scan = %v, want found %v", infos, test.found)
+			}
+			_, _, err = provider.Peek(context.Background(), "s1")
+			if test.found && err != nil {
+				t.Fatalf("Peek error: %v", err)
+			}
+			if !test.found && !errors.Is(err, fasthttpsession.ErrSessionNotFound) {
+				t.Fatalf("Peek error = %v, want ErrSessionNotFound", err)
+			}
+			sessionIds, err := provider.UserSessions(context.Background(), "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := len(sessionIds) == 1; found != test.found {
+				t.Fatalf("UserSessions = %v, want found %v", sessionIds, test.found)
+			}
+		})
+	}
+}
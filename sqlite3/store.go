@@ -1,6 +1,7 @@
 package sqlite3
 
 import (
+	"context"
 	"time"
 
 	"github.com/brunohass/fasthttpsession"
@@ -10,34 +11,51 @@ import (
 // session sqlite3 store
 
 // new default sqlite3 store
-func NewSqLite3Store(sessionId string) *Store {
-	sqlite3Store := &Store{}
+func NewSqLite3Store(provider *Provider, sessionId string) *Store {
+	sqlite3Store := &Store{provider: provider}
 	sqlite3Store.Init(sessionId, make(map[string]interface{}))
 	return sqlite3Store
 }
 
 // new sqlite3 store data
-func NewSqLite3StoreData(sessionId string, data map[string]interface{}) *Store {
-	sqlite3Store := &Store{}
+func NewSqLite3StoreData(provider *Provider, sessionId string, data map[string]interface{}) *Store {
+	sqlite3Store := &Store{provider: provider}
 	sqlite3Store.Init(sessionId, data)
 	return sqlite3Store
 }
 
 type Store struct {
 	fasthttpsession.Store
+	provider *Provider
 }
 
 // save store
 func (ss *Store) Save(ctx *fasthttp.RequestCtx) error {
+	return ss.SaveContext(fasthttpsession.ContextWithRequestCtx(context.Background(), ctx))
+}
+
+// save store with context
+// an unchanged store only updates the last active time
+// fasthttpsession.ErrConflict is returned if the session version moved since it was read
+func (ss *Store) SaveContext(ctx context.Context) error {
+
+	if !ss.IsDirty() {
+		_, err := ss.provider.sessionDao.updateLastActiveBySessionId(ctx, ss.GetSessionId(), time.Now().Unix())
+		return err
+	}
 
-	b, err := provider.config.SerializeFunc(ss.GetAll())
+	b, err := ss.provider.config.SerializeFunc(ss.Export())
+	if err != nil {
+		return err
+	}
+	rows, err := ss.provider.sessionDao.updateBySessionId(ctx, ss.GetSessionId(), string(b), time.Now().Unix(), int64(ss.TTL()/time.Second), ss.UserID(), ss.Version())
 	if err != nil {
 		return err
 	}
-	session, err := provider.sessionDao.getSessionBySessionId(ss.GetSessionId())
-	if err != nil || len(session) == 0 {
-		return nil
+	if rows == 0 {
+		return fasthttpsession.ErrConflict
 	}
-	_, err = provider.sessionDao.updateBySessionId(ss.GetSessionId(), string(b), time.Now().Unix())
-	return err
+	ss.MarkSaved()
+	ss.SetSize(len(b))
+	return nil
 }
@@ -1,6 +1,12 @@
 package fasthttpsession
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/valyala/fasthttp"
 )
 
@@ -9,23 +15,110 @@ import (
 type SessionStore interface {
 	Save(*fasthttp.RequestCtx) error
 	Get(key string) interface{}
+	GetString(key string) (string, error)
+	GetInt64(key string) (int64, error)
+	GetBool(key string) (bool, error)
+	GetTime(key string) (time.Time, error)
 	GetAll() map[string]interface{}
 	Set(key string, value interface{})
 	Delete(key string)
 	Flush()
 	GetSessionId() string
+	CreatedAt() time.Time
+	SetTTL(ttl time.Duration)
+	TTL() time.Duration
+	Fingerprint() string
+	SetFingerprint(fingerprint string)
+	UserID() string
+	SetUserID(userID string)
+	Size() int
+	AddFlash(category string, value interface{})
+	Flashes(category string) []interface{}
+}
+
+// ContextSessionStore is a SessionStore whose Save honours the cancellation
+// and deadline of a context.Context. All bundled stores implement it.
+type ContextSessionStore interface {
+	SessionStore
+	SaveContext(context.Context) error
 }
 
+// ErrConflict is returned by Save when the session was saved by another request
+// since it was read, see Session.Update
+var ErrConflict = errors.New("session save conflict, the session was changed since it was read")
+
+// flash messages key prefix in the session data
+const flashKeyPrefix = "_flash_"
+
+// session meta data key in the exported session data
+const (
+	metaKey            = "_meta_"
+	metaCreatedKey     = "created"
+	metaTTLKey         = "ttl"
+	metaVersionKey     = "version"
+	metaFingerprintKey = "fingerprint"
+	metaUserKey        = "user"
+)
+
 type Store struct {
 	sessionId string
 	data      *CCMap
+	flashLock sync.Mutex
+
+	// 1 when the data changed since Init or the last MarkClean
+	dirty int32
+
+	// session create time(unix)
+	createdAt int64
+
+	// session idle lifetime(s), 0 means the provider lifetime
+	ttl int64
+
+	// session version read from the provider, incremented by every save of changed data
+	version int64
+
+	// fingerprint of the client bound to the session, see Config.FingerprintFunc
+	fingerprint atomic.Value
+
+	// id of the user owning the session, indexed by the provider
+	userID atomic.Value
+
+	// size in bytes of the serialized session data last read or saved
+	size int64
 }
 
 // init store data and sessionId
+// data is the user data, or the data exported by Export
 func (s *Store) Init(sessionId string, data map[string]interface{}) {
 	s.sessionId = sessionId
 	s.data = NewDefaultCCMap()
-	s.data.MSet(data)
+	meta, hasMeta := data[metaKey].(map[string]interface{})
+	for key, value := range data {
+		if key != metaKey {
+			s.data.Set(key, value)
+		}
+	}
+
+	s.createdAt, _ = getValue[int64](meta[metaCreatedKey], metaCreatedKey)
+	if s.createdAt == 0 {
+		s.createdAt = time.Now().Unix()
+	}
+	ttl, _ := getValue[int64](meta[metaTTLKey], metaTTLKey)
+	atomic.StoreInt64(&s.ttl, ttl)
+	version, _ := getValue[int64](meta[metaVersionKey], metaVersionKey)
+	atomic.StoreInt64(&s.version, version)
+	fingerprint, _ := getValue[string](meta[metaFingerprintKey], metaFingerprintKey)
+	s.fingerprint.Store(fingerprint)
+	userID, _ := getValue[string](meta[metaUserKey], metaUserKey)
+	s.userID.Store(userID)
+	atomic.StoreInt64(&s.size, 0)
+
+	// a new session is dirty until its meta data is saved
+	if hasMeta {
+		atomic.StoreInt32(&s.dirty, 0)
+	} else {
+		atomic.StoreInt32(&s.dirty, 1)
+	}
 }
 
 // get data by key
@@ -33,27 +126,221 @@ func (s *Store) Get(key string) interface{} {
 	return s.data.Get(key)
 }
 
+// get string data by key
+func (s *Store) GetString(key string) (string, error) {
+	return getValue[string](s.Get(key), key)
+}
+
+// get int64 data by key, other numeric types are converted
+func (s *Store) GetInt64(key string) (int64, error) {
+	return getValue[int64](s.Get(key), key)
+}
+
+// get bool data by key
+func (s *Store) GetBool(key string) (bool, error) {
+	return getValue[bool](s.Get(key), key)
+}
+
+// get time data by key, RFC 3339 strings are parsed
+func (s *Store) GetTime(key string) (time.Time, error) {
+	return getValue[time.Time](s.Get(key), key)
+}
+
 // get all data
 func (s *Store) GetAll() map[string]interface{} {
 	return s.data.GetAll()
 }
 
+// get all data with the session meta data, providers serialize the exported data
+// the exported version is the next version, the one the save creates
+func (s *Store) Export() map[string]interface{} {
+	data := s.data.GetAll()
+	meta := map[string]interface{}{
+		metaCreatedKey: s.createdAt,
+		metaTTLKey:     atomic.LoadInt64(&s.ttl),
+		metaVersionKey: atomic.LoadInt64(&s.version) + 1,
+	}
+	if fingerprint := s.Fingerprint(); fingerprint != "" {
+		meta[metaFingerprintKey] = fingerprint
+	}
+	if userID := s.UserID(); userID != "" {
+		meta[metaUserKey] = userID
+	}
+	data[metaKey] = meta
+	return data
+}
+
 // set data
 func (s *Store) Set(key string, value interface{}) {
 	s.data.Set(key, value)
+	s.markDirty()
 }
 
 // delete data by key
 func (s *Store) Delete(key string) {
 	s.data.Delete(key)
+	s.markDirty()
 }
 
 // flush all data
 func (s *Store) Flush() {
 	s.data.Clear()
+	s.markDirty()
 }
 
 // get session id
 func (s *Store) GetSessionId() string {
 	return s.sessionId
 }
+
+// get session create time
+func (s *Store) CreatedAt() time.Time {
+	return time.Unix(s.createdAt, 0)
+}
+
+// set the session idle lifetime, e.g. 30 days for a "remember me" login,
+// 0 restores the provider lifetime, a part of a second is rounded up.
+// Session.Save and the middleware also update the cookie expiry.
+func (s *Store) SetTTL(ttl time.Duration) {
+	seconds := int64(0)
+	if ttl > 0 {
+		seconds = int64(ttl / time.Second)
+		if ttl%time.Second != 0 {
+			seconds++
+		}
+	}
+	atomic.StoreInt64(&s.ttl, seconds)
+	s.markDirty()
+}
+
+// get the session idle lifetime, 0 means the provider lifetime
+func (s *Store) TTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.ttl)) * time.Second
+}
+
+// get the fingerprint of the client bound to the session, empty if it is not bound
+func (s *Store) Fingerprint() string {
+	fingerprint, _ := s.fingerprint.Load().(string)
+	return fingerprint
+}
+
+// bind the session to the client fingerprint
+func (s *Store) SetFingerprint(fingerprint string) {
+	s.fingerprint.Store(fingerprint)
+	s.markDirty()
+}
+
+// get the session info of the store meta data,
+// providers add the size and last active time they keep
+func (s *Store) Info() SessionInfo {
+	return SessionInfo{
+		SessionId: s.sessionId,
+		CreatedAt: s.CreatedAt(),
+		TTL:       s.TTL(),
+		UserID:    s.UserID(),
+	}
+}
+
+// get the id of the user owning the session, empty if there is none
+func (s *Store) UserID() string {
+	userID, _ := s.userID.Load().(string)
+	return userID
+}
+
+// set the id of the user owning the session, e.g. on login,
+// Session.ListUserSessions and Session.DestroyUserSessions find the session by it
+func (s *Store) SetUserID(userID string) {
+	s.userID.Store(userID)
+	s.markDirty()
+}
+
+// session idle lifetime(s), the TTL of the session or else maxLifeTime
+func (s *Store) IdleLifetime(maxLifeTime int64) int64 {
+	if ttl := atomic.LoadInt64(&s.ttl); ttl > 0 {
+		return ttl
+	}
+	return maxLifeTime
+}
+
+// session lifetime(s) from now, the idle lifetime capped by what is left
+// of the absoluteLifeTime since creation, 0 absoluteLifeTime means no limit
+func (s *Store) Lifetime(maxLifeTime int64, absoluteLifeTime int64) int64 {
+	idleLifeTime := s.IdleLifetime(maxLifeTime)
+	if absoluteLifeTime <= 0 {
+		return idleLifeTime
+	}
+	left := s.createdAt + absoluteLifeTime - time.Now().Unix()
+	if left < 1 {
+		left = 1
+	}
+	if left < idleLifeTime {
+		return left
+	}
+	return idleLifeTime
+}
+
+// add a flash message to category, it is kept until read by Flashes
+func (s *Store) AddFlash(category string, value interface{}) {
+	s.flashLock.Lock()
+	defer s.flashLock.Unlock()
+
+	key := flashKeyPrefix + category
+	flashes, _ := s.data.Get(key).([]interface{})
+	s.data.Set(key, append(flashes, value))
+	s.markDirty()
+}
+
+// get and remove the flash messages of category
+func (s *Store) Flashes(category string) []interface{} {
+	s.flashLock.Lock()
+	defer s.flashLock.Unlock()
+
+	flashes, _ := s.data.GetOnce(flashKeyPrefix + category).([]interface{})
+	if len(flashes) > 0 {
+		s.markDirty()
+	}
+	return flashes
+}
+
+// data changed since the store was read or last saved,
+// providers only refresh the session lifetime of a clean store on save
+func (s *Store) IsDirty() bool {
+	return atomic.LoadInt32(&s.dirty) == 1
+}
+
+// mark the data as saved
+func (s *Store) MarkClean() {
+	atomic.StoreInt32(&s.dirty, 0)
+}
+
+// get the session version read from the provider,
+// providers compare it with the stored version to detect conflicts
+func (s *Store) Version() int64 {
+	return atomic.LoadInt64(&s.version)
+}
+
+// set the session version, for providers which keep it outside the session data
+func (s *Store) SetVersion(version int64) {
+	atomic.StoreInt64(&s.version, version)
+}
+
+// get the size in bytes of the serialized session data last read or saved,
+// 0 if the provider does not serialize it
+func (s *Store) Size() int {
+	return int(atomic.LoadInt64(&s.size))
+}
+
+// set the size of the serialized session data, for providers on read and save
+func (s *Store) SetSize(size int) {
+	atomic.StoreInt64(&s.size, int64(size))
+}
+
+// mark the exported data as saved, the store takes the saved version
+func (s *Store) MarkSaved() {
+	atomic.AddInt64(&s.version, 1)
+	s.MarkClean()
+}
+
+func (s *Store) markDirty() {
+	atomic.StoreInt32(&s.dirty, 1)
+}
@@ -0,0 +1,61 @@
+package fasthttpsession
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreSetTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"zero", 0, 0},
+		{"seconds", 30 * 24 * time.Hour, 30 * 24 * time.Hour},
+		{"sub-second", 500 * time.Millisecond, time.Second},
+		{"fraction rounded up", 1500 * time.Millisecond, 2 * time.Second},
+		{"negative", -time.Second, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &Store{}
+			store.Init("sessionId", map[string]interface{}{})
+			store.SetTTL(test.ttl)
+			if got := store.TTL(); got != test.want {
+				t.Fatalf("SetTTL(%s) TTL = %s, want %s", test.ttl, got, test.want)
+			}
+		})
+	}
+}
+
+func TestStoreLifetime(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name             string
+		ttl              time.Duration
+		createdAt        int64
+		maxLifeTime      int64
+		absoluteLifeTime int64
+		want             int64
+	}{
+		{"provider lifetime", 0, now, 60, 0, 60},
+		{"session ttl", time.Hour, now, 60, 0, 3600},
+		{"capped by the absolute lifetime", time.Hour, now - 100, 60, 130, 30},
+		{"absolute lifetime reached", 0, now - 200, 60, 100, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &Store{}
+			store.Init("sessionId", map[string]interface{}{
+				metaKey: map[string]interface{}{metaCreatedKey: test.createdAt},
+			})
+			store.SetTTL(test.ttl)
+			got := store.Lifetime(test.maxLifeTime, test.absoluteLifeTime)
+			// a second may pass during the test
+			if got != test.want && got != test.want-1 {
+				t.Fatalf("Lifetime = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
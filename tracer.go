@@ -0,0 +1,86 @@
+package fasthttpsession
+
+import (
+	"context"
+)
+
+// span attribute keys
+const (
+	// provider config name, e.g. "redis"
+	AttributeProvider = "session.provider"
+
+	// size in bytes of the session data read or written
+	AttributeSize = "session.size"
+
+	// backend of a provider round-trip, e.g. "redis", "mysql"
+	AttributeDBSystem = "db.system"
+
+	// backend operation, e.g. "GET", "UPDATE"
+	AttributeDBOperation = "db.operation"
+
+	// backend statement, e.g. the SQL query
+	AttributeDBStatement = "db.statement"
+)
+
+// Tracer starts the spans of the provider calls and of their backend round-trips.
+// It has the shape of the OpenTelemetry trace API, an adapter wraps a trace.Tracer
+// and converts the attributes. ctx is the parent, the returned ctx carries the span.
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is a started span, like an OpenTelemetry trace.Span
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a span attribute, Value is a string, an int or a bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// return a string attribute
+func StringAttribute(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// return an int attribute
+func IntAttribute(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+type tracerKey struct{}
+
+// ContextWithTracer returns a copy of parent which carries the tracer of the provider calls
+func ContextWithTracer(parent context.Context, tracer Tracer) context.Context {
+	return context.WithValue(parent, tracerKey{}, tracer)
+}
+
+// StartSpan starts a span with the tracer carried by ctx, providers wrap their backend
+// round-trips in it. Without tracer the span does nothing and ctx is returned.
+func StartSpan(ctx context.Context, spanName string, attributes ...Attribute) (context.Context, Span) {
+	tracer, _ := ctx.Value(tracerKey{}).(Tracer)
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, spanName, attributes...)
+}
+
+// EndSpan records err, if any, and ends span
+func EndSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...Attribute) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
@@ -0,0 +1,56 @@
+package fasthttpsession
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrUserIndexNotSupported = errors.New("session user index error, provider does not implement UserIndexProvider")
+
+// ListUserSessions returns the ids of the sessions of the user, see SessionStore.SetUserID,
+// e.g. for an "active devices" page
+func (s *Session) ListUserSessions(ctx context.Context, userID string) ([]string, error) {
+	provider, err := s.userIndexProvider(userID)
+	if err != nil {
+		return nil, err
+	}
+	return provider.UserSessions(ctx, userID)
+}
+
+// DestroyUserSessions destroys all the sessions of the user, e.g. to "log out everywhere"
+func (s *Session) DestroyUserSessions(ctx context.Context, userID string) error {
+	provider, err := s.userIndexProvider(userID)
+	if err != nil {
+		return err
+	}
+	if s.config.Hooks.OnDestroy == nil && s.config.Metrics == nil {
+		return provider.DestroyUserSessions(ctx, userID)
+	}
+
+	sessionIds, err := provider.UserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = provider.DestroyUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, sessionId := range sessionIds {
+		s.onDestroy(nil, sessionId)
+	}
+	return nil
+}
+
+func (s *Session) userIndexProvider(userID string) (UserIndexProvider, error) {
+	if s.provider == nil {
+		return nil, errors.New("session user index error, not set provider")
+	}
+	if userID == "" {
+		return nil, errors.New("session user index error, user id is empty")
+	}
+	provider, ok := s.provider.(UserIndexProvider)
+	if !ok {
+		return nil, ErrUserIndexNotSupported
+	}
+	return provider, nil
+}
@@ -0,0 +1,130 @@
+package fasthttpsession
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("session value not found")
+
+// TypeError is returned when a session value can not be converted to the requested type
+type TypeError struct {
+	Key   string
+	Value interface{}
+	Type  reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("session value %s of type %T can not be converted to %s", e.Key, e.Value, e.Type)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Get returns the session value of key as T.
+// Numbers are converted between numeric types when the value fits without loss,
+// so json decoded float64 can be read as int64. time.Time is also parsed
+// from RFC 3339 strings.
+func Get[T any](sessionStore SessionStore, key string) (T, error) {
+	return getValue[T](sessionStore.Get(key), key)
+}
+
+func getValue[T any](value interface{}, key string) (T, error) {
+	var result T
+	if value == nil {
+		return result, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+	typ := reflect.TypeOf(&result).Elem()
+	converted, ok := convertValue(reflect.ValueOf(value), typ)
+	if !ok {
+		return result, &TypeError{Key: key, Value: value, Type: typ}
+	}
+	return converted.Interface().(T), nil
+}
+
+// convert value to typ, ok is false if it does not fit
+func convertValue(value reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	if typ == timeType {
+		if value.Kind() != reflect.String {
+			return value, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, value.String())
+		if err != nil {
+			return value, false
+		}
+		return reflect.ValueOf(t), true
+	}
+
+	target := reflect.New(typ).Elem()
+	switch {
+	case isIntKind(value.Kind()):
+		v := value.Int()
+		switch {
+		case isIntKind(typ.Kind()):
+			if target.OverflowInt(v) {
+				return value, false
+			}
+		case isUintKind(typ.Kind()):
+			if v < 0 || target.OverflowUint(uint64(v)) {
+				return value, false
+			}
+		case !isFloatKind(typ.Kind()):
+			return value, false
+		}
+	case isUintKind(value.Kind()):
+		v := value.Uint()
+		switch {
+		case isIntKind(typ.Kind()):
+			if v > math.MaxInt64 || target.OverflowInt(int64(v)) {
+				return value, false
+			}
+		case isUintKind(typ.Kind()):
+			if target.OverflowUint(v) {
+				return value, false
+			}
+		case !isFloatKind(typ.Kind()):
+			return value, false
+		}
+	case isFloatKind(value.Kind()):
+		v := value.Float()
+		switch {
+		case isIntKind(typ.Kind()):
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 || target.OverflowInt(int64(v)) {
+				return value, false
+			}
+		case isUintKind(typ.Kind()):
+			if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 || target.OverflowUint(uint64(v)) {
+				return value, false
+			}
+		case isFloatKind(typ.Kind()):
+			if target.OverflowFloat(v) {
+				return value, false
+			}
+		default:
+			return value, false
+		}
+	default:
+		// named types of the same kind, e.g. type Role string
+		if value.Kind() != typ.Kind() || !value.Type().ConvertibleTo(typ) {
+			return value, false
+		}
+	}
+	return value.Convert(typ), true
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uintptr
+}
+
+func isFloatKind(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
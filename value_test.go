@@ -0,0 +1,123 @@
+package fasthttpsession
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type role string
+
+func TestGetValueInt64(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  int64
+		ok    bool
+	}{
+		{"int64", int64(42), 42, true},
+		{"int", 42, 42, true},
+		{"uint8", uint8(7), 7, true},
+		{"json float64", float64(1e6), 1e6, true},
+		{"negative float64", float64(-3), -3, true},
+		{"fractional float64", 1.5, 0, false},
+		{"float64 overflow", math.MaxFloat64, 0, false},
+		{"uint64 overflow", uint64(math.MaxUint64), 0, false},
+		{"string", "42", 0, false},
+		{"bool", true, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getValue[int64](test.value, "key")
+			if test.ok {
+				if err != nil {
+					t.Fatalf("getValue(%v) error: %v", test.value, err)
+				}
+				if got != test.want {
+					t.Fatalf("getValue(%v) = %d, want %d", test.value, got, test.want)
+				}
+				return
+			}
+			var typeErr *TypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("getValue(%v) error = %v, want *TypeError", test.value, err)
+			}
+		})
+	}
+}
+
+func TestGetValueNarrowTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		get  func() (interface{}, error)
+		want interface{}
+		ok   bool
+	}{
+		{"int8 fits", func() (interface{}, error) { return getValue[int8](int64(127), "key") }, int8(127), true},
+		{"int8 overflow", func() (interface{}, error) { return getValue[int8](int64(128), "key") }, nil, false},
+		{"uint negative", func() (interface{}, error) { return getValue[uint](-1, "key") }, nil, false},
+		{"uint16 from float64", func() (interface{}, error) { return getValue[uint16](float64(65535), "key") }, uint16(65535), true},
+		{"float32 from int", func() (interface{}, error) { return getValue[float32](3, "key") }, float32(3), true},
+		{"float32 overflow", func() (interface{}, error) { return getValue[float32](math.MaxFloat64, "key") }, nil, false},
+		{"named string", func() (interface{}, error) { return getValue[role]("admin", "key") }, role("admin"), true},
+		{"string from named", func() (interface{}, error) { return getValue[string](role("admin"), "key") }, "admin", true},
+		{"string from int", func() (interface{}, error) { return getValue[string](65, "key") }, nil, false},
+		{"bool from int", func() (interface{}, error) { return getValue[bool](1, "key") }, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.get()
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if got != test.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, test.want, test.want)
+			}
+		})
+	}
+}
+
+func TestGetValueTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		ok    bool
+	}{
+		{"time", now, true},
+		{"RFC 3339 string", now.Format(time.RFC3339Nano), true},
+		{"invalid string", "yesterday", false},
+		{"unix seconds", now.Unix(), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getValue[time.Time](test.value, "key")
+			if !test.ok {
+				if err == nil {
+					t.Fatalf("getValue(%v) = %v, want an error", test.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getValue(%v) error: %v", test.value, err)
+			}
+			if !got.Equal(now) {
+				t.Fatalf("getValue(%v) = %v, want %v", test.value, got, now)
+			}
+		})
+	}
+}
+
+func TestGetValueNotFound(t *testing.T) {
+	_, err := getValue[string](nil, "name")
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("error = %v, want ErrKeyNotFound", err)
+	}
+}